@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	goalibs "github.com/geeksmy/go-libs/goa-libs"
+	"github.com/geeksmy/go-libs/jwt"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+type confFlags struct {
+	path string
+	key  string
+}
+
+func (c *confFlags) bind(flagSet *pflag.FlagSet) {
+	flagSet.StringVar(&c.path, "config", "", "YAML file containing jwt.Conf")
+	flagSet.StringVar(&c.key, "config-key", "JWT", "key of jwt.Conf in --config, empty for the top level")
+}
+
+// load 读取 --config 中的 jwt.Conf, 和服务一样支持 file:// 和 env:// 的密钥来源
+func (c *confFlags) load() (jwt.Conf, error) {
+	conf := jwt.Conf{}
+	v, err := c.read()
+	if err != nil {
+		return conf, err
+	}
+
+	if c.key == "" {
+		err = v.Unmarshal(&conf)
+	} else {
+		if !v.IsSet(c.key) {
+			return conf, fmt.Errorf("config %s has no %s", c.path, c.key)
+		}
+		err = v.UnmarshalKey(c.key, &conf)
+	}
+	if err != nil {
+		return conf, fmt.Errorf("parse config %s: %w", c.path, err)
+	}
+	return conf, nil
+}
+
+// loadScopes 读取 --config 中 key 下的 goalibs.ScopeConf, 没有配置时和服务一样使用默认规则
+func (c *confFlags) loadScopes(key string) (*goalibs.ScopeModel, error) {
+	v, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+
+	conf := goalibs.ScopeConf{}
+	if key != "" && v.IsSet(key) {
+		if err := v.UnmarshalKey(key, &conf); err != nil {
+			return nil, fmt.Errorf("parse config %s: %w", c.path, err)
+		}
+	}
+	model, err := goalibs.NewScopeModelWithConf(conf)
+	if err != nil {
+		return nil, fmt.Errorf("parse config %s: %w", c.path, err)
+	}
+	return model, nil
+}
+
+func (c *confFlags) read() (*viper.Viper, error) {
+	if c.path == "" {
+		return nil, fmt.Errorf("--config is required")
+	}
+
+	v := viper.New()
+	v.SetConfigFile(c.path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("read config %s: %w", c.path, err)
+	}
+	return v, nil
+}
+
+// readToken 从参数读取 token, 参数为 - 时从标准输入读取
+func readToken(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected exactly one token argument, got %d", len(args))
+	}
+	if args[0] != "-" {
+		return strings.TrimSpace(args[0]), nil
+	}
+
+	content, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(content)), nil
+}
+
+// newFlagSet 返回子命令的 FlagSet, --help 时 Parse 返回 pflag.ErrHelp, run 把它当作成功
+func newFlagSet(name string, stdout io.Writer) *pflag.FlagSet {
+	flagSet := pflag.NewFlagSet("jwtctl "+name, pflag.ContinueOnError)
+	flagSet.SetOutput(stdout)
+	flagSet.SortFlags = false
+	return flagSet
+}
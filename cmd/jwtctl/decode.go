@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+)
+
+// runDecode 不验证签名, 输出 token 的 header 和 claims, 并把 exp/iat/nbf 转换为时间
+func runDecode(args []string, stdout io.Writer) error {
+	flagSet := newFlagSet("decode", stdout)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	token, err := readToken(flagSet.Args())
+	if err != nil {
+		return err
+	}
+
+	header, claims, err := decodeToken(token)
+	if err != nil {
+		return err
+	}
+
+	if err := printJSON(stdout, "header", header); err != nil {
+		return err
+	}
+	if err := printJSON(stdout, "claims", claims); err != nil {
+		return err
+	}
+	printTimes(stdout, claims, time.Now())
+	return nil
+}
+
+func decodeToken(token string) (map[string]interface{}, jwtlib.MapClaims, error) {
+	claims := jwtlib.MapClaims{}
+	parsed, _, err := new(jwtlib.Parser).ParseUnverified(token, claims)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decode token: %w", err)
+	}
+	return parsed.Header, claims, nil
+}
+
+func printJSON(w io.Writer, title string, v interface{}) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s:\n%s\n", title, content)
+	return err
+}
+
+// printTimes 输出 exp/iat/nbf 对应的 UTC 时间以及和 now 的距离
+func printTimes(w io.Writer, claims jwtlib.MapClaims, now time.Time) {
+	for _, name := range []string{"iat", "nbf", "exp"} {
+		v, ok := claims[name].(float64)
+		if !ok {
+			continue
+		}
+		t := time.Unix(int64(v), 0).UTC()
+		fmt.Fprintf(w, "%s: %s (%s)\n", name, t.Format(time.RFC3339), relativeTime(t, now))
+	}
+}
+
+func relativeTime(t, now time.Time) string {
+	d := t.Sub(now).Round(time.Second)
+	if d < 0 {
+		return (-d).String() + " ago"
+	}
+	return "in " + d.String()
+}
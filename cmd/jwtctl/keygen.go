@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+type keygenFlags struct {
+	keyType string
+	bits    int
+	curve   string
+	bytes   int
+	out     string
+	pubOut  string
+}
+
+// runKeygen 生成 jwt.Conf 使用的密钥
+// RSA 私钥为 PKCS#1, EC 私钥为 SEC 1, Ed25519 私钥为 PKCS#8, 公钥都为 PKIX, HMAC secret 为 base64 字符串
+func runKeygen(args []string, stdout io.Writer) error {
+	f := keygenFlags{}
+	flagSet := newFlagSet("keygen", stdout)
+	flagSet.StringVar(&f.keyType, "type", "rsa", "key type: rsa, ec, ed25519, hmac")
+	flagSet.IntVar(&f.bits, "bits", 2048, "rsa key size")
+	flagSet.StringVar(&f.curve, "curve", "P-256", "ec curve: P-256 (ES256), P-384 (ES384), P-521 (ES512)")
+	flagSet.IntVar(&f.bytes, "bytes", 32, "hmac secret size in bytes, at least 16")
+	flagSet.StringVar(&f.out, "out", "", "write the private key or secret to the file instead of stdout")
+	flagSet.StringVar(&f.pubOut, "pub-out", "", "write the public key to the file instead of stdout")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	if f.keyType == "hmac" {
+		if f.bytes < 16 {
+			return fmt.Errorf("--bytes must be at least 16")
+		}
+		secret := make([]byte, f.bytes)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+		return writeKey(stdout, f.out, []byte(base64.StdEncoding.EncodeToString(secret)+"\n"))
+	}
+
+	priv, pub, err := generateKeyPair(f)
+	if err != nil {
+		return err
+	}
+
+	if err := writeKey(stdout, f.out, pem.EncodeToMemory(priv)); err != nil {
+		return err
+	}
+	return writeKey(stdout, f.pubOut, pem.EncodeToMemory(pub))
+}
+
+func generateKeyPair(f keygenFlags) (*pem.Block, *pem.Block, error) {
+	var privBlock *pem.Block
+	var pubKey interface{}
+
+	switch f.keyType {
+	case "rsa":
+		if f.bits < 2048 {
+			return nil, nil, fmt.Errorf("--bits must be at least 2048")
+		}
+		key, err := rsa.GenerateKey(rand.Reader, f.bits)
+		if err != nil {
+			return nil, nil, err
+		}
+		privBlock = &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+		pubKey = &key.PublicKey
+	case "ec":
+		curve, err := ecCurve(f.curve)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		privBlock = &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+		pubKey = &key.PublicKey
+	case "ed25519":
+		pub, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		privBlock = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		pubKey = pub
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", f.keyType)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privBlock, &pem.Block{Type: "PUBLIC KEY", Bytes: der}, nil
+}
+
+func ecCurve(name string) (elliptic.Curve, error) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", name)
+	}
+}
+
+// writeKey filename 为空时写入 stdout, 文件只有当前用户可以读写
+func writeKey(stdout io.Writer, filename string, content []byte) error {
+	if filename == "" {
+		_, err := stdout.Write(content)
+		return err
+	}
+	return ioutil.WriteFile(filename, content, 0600)
+}
@@ -0,0 +1,81 @@
+// jwtctl 生成 jwt 密钥, 签发, 解码和验证 jwt, 用于排查 jwt 相关的问题
+//
+//	jwtctl keygen --type rsa --bits 2048 --out jwt.key --pub-out jwt.key.pub
+//	jwtctl mint --config conf.yaml --sub alice --roles admin --scopes api:read
+//	jwtctl decode <token>
+//	jwtctl verify --config conf.yaml --scopes api:read <token>
+//
+// --config 为包含 jwt.Conf 的 YAML 文件, 默认读取 JWT 下的配置, 和服务使用的配置文件相同
+// verify 同时读取 Scopes 下的 goalibs.ScopeConf
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/spf13/pflag"
+)
+
+type command struct {
+	usage string
+	run   func(args []string, stdout io.Writer) error
+}
+
+var commands = map[string]command{
+	"keygen": {"generate RSA, EC, Ed25519 or HMAC key material for jwt.Conf", runKeygen},
+	"mint":   {"sign a UserClaims token with the keys in --config", runMint},
+	"decode": {"decode a token without verifying it", runDecode},
+	"verify": {"verify a token against --config like goalibs.JwtAuth", runVerify},
+}
+
+var errUsage = errors.New("missing command")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "jwtctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		printUsage(os.Stderr)
+		return errUsage
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(stdout)
+		return nil
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command %q", name)
+	}
+	if err := cmd.run(args[1:], stdout); !errors.Is(err, pflag.ErrHelp) {
+		return err
+	}
+	return nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: jwtctl <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "run 'jwtctl <command> --help' for the flags of a command")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConf(t *testing.T, dir, conf string) string {
+	filename := filepath.Join(dir, "conf.yaml")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte(conf), 0600))
+	return filename
+}
+
+func runOutput(t *testing.T, args ...string) (string, error) {
+	out := &bytes.Buffer{}
+	err := run(args, out)
+	return out.String(), err
+}
+
+func TestKeygen(t *testing.T) {
+	dir := t.TempDir()
+
+	cases := []struct {
+		keyType string
+		confKey string
+		args    []string
+	}{
+		{"rsa", "RSAPrivateKey", nil},
+		{"ec", "ECPrivateKey", []string{"--curve", "P-384"}},
+		{"ed25519", "EdPrivateKey", nil},
+		{"hmac", "Secret", nil},
+	}
+
+	for _, tc := range cases {
+		keyFile := filepath.Join(dir, tc.keyType+".key")
+		args := append([]string{"keygen", "--type", tc.keyType, "--out", keyFile}, tc.args...)
+		out, err := runOutput(t, args...)
+		assert.NoError(t, err, tc.keyType)
+		if tc.keyType == "hmac" {
+			assert.Empty(t, out)
+		} else {
+			assert.Contains(t, out, "-----BEGIN PUBLIC KEY-----", tc.keyType)
+		}
+
+		// 生成的密钥可以直接用于 jwt.Conf
+		conf := writeConf(t, dir, "JWT:\n  "+tc.confKey+": file://"+keyFile+"\n")
+		token, err := runOutput(t, "mint", "--config", conf, "--sub", "alice", "--roles", "guest")
+		assert.NoError(t, err, tc.keyType)
+
+		out, err = runOutput(t, "verify", "--config", conf, strings.TrimSpace(token))
+		assert.NoError(t, err, tc.keyType)
+		assert.Contains(t, out, "result: allowed", tc.keyType)
+	}
+
+	_, err := runOutput(t, "keygen", "--type", "dsa")
+	assert.Error(t, err)
+	_, err = runOutput(t, "keygen", "--type", "rsa", "--bits", "1024")
+	assert.Error(t, err)
+}
+
+func TestMintAndDecode(t *testing.T) {
+	conf := writeConf(t, t.TempDir(), `
+JWT:
+  Secret: ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM
+  TokenIssuer: authority
+`)
+
+	token, err := runOutput(t, "mint", "--config", conf, "--sub", "alice", "--aud", "api,web",
+		"--scopes", "api:read", "--meta", "tenant=acme", "--jti", "jti-1", "--ttl", "1h")
+	assert.NoError(t, err)
+
+	out, err := runOutput(t, "decode", strings.TrimSpace(token))
+	assert.NoError(t, err)
+	assert.Contains(t, out, `"alg": "HS512"`)
+	assert.Contains(t, out, `"iss": "authority"`)
+	assert.Contains(t, out, `"jti": "jti-1"`)
+	assert.Contains(t, out, `"tenant": "acme"`)
+	assert.Contains(t, out, "exp: ")
+
+	_, err = runOutput(t, "decode", "not-a-token")
+	assert.Error(t, err)
+
+	_, err = runOutput(t, "mint", "--sub", "alice")
+	assert.Error(t, err)
+}
+
+func TestVerify(t *testing.T) {
+	conf := writeConf(t, t.TempDir(), `
+JWT:
+  Secret: ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM
+  AccessList:
+    - deny roles suspended
+    - allow roles admin
+`)
+
+	token, err := runOutput(t, "mint", "--config", conf, "--sub", "alice", "--roles", "admin", "--scopes", "api:*")
+	assert.NoError(t, err)
+	token = strings.TrimSpace(token)
+
+	out, err := runOutput(t, "verify", "--config", conf, "--scopes", "api:read", token)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "token:  valid")
+	assert.Contains(t, out, `acl:    allowed by #1 "allow roles admin"`)
+	assert.Contains(t, out, "result: allowed")
+
+	out, err = runOutput(t, "verify", "--config", conf, "--user-id-claim", "sub", token)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "result: allowed, user id alice")
+
+	out, err = runOutput(t, "verify", "--config", conf, "--scopes", "user:write", token)
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, out, "result: denied, forbidden: missing scopes: user:write")
+
+	suspended, err := runOutput(t, "mint", "--config", conf, "--roles", "admin,suspended")
+	assert.NoError(t, err)
+	out, err = runOutput(t, "verify", "--config", conf, "--locale", "en", strings.TrimSpace(suspended))
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, out, `acl:    denied by #0 "deny roles suspended"`)
+	assert.Contains(t, out, "result: denied, unauthorized: access denied")
+
+	expired, err := runOutput(t, "mint", "--config", conf, "--roles", "admin", "--ttl", "1s")
+	assert.NoError(t, err)
+	time.Sleep(time.Second * 2)
+	out, err = runOutput(t, "verify", "--config", conf, strings.TrimSpace(expired))
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, out, "token:  invalid, token is expired")
+}
+
+// verify 和服务使用同一份配置中的 Scopes 和 StrictRoles
+func TestVerify_ServerConf(t *testing.T) {
+	conf := writeConf(t, t.TempDir(), `
+JWT:
+  Secret: ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM
+  StrictRoles: true
+  AccessList:
+    - allow roles guest editor
+Scopes:
+  Implies:
+    editor: ["api:write"]
+`)
+
+	// 默认的 ScopeModel 中 editor 不包含 api:write
+	token, err := runOutput(t, "mint", "--config", conf, "--roles", "editor", "--scopes", "editor")
+	assert.NoError(t, err)
+	out, err := runOutput(t, "verify", "--config", conf, "--scopes", "api:write", strings.TrimSpace(token))
+	assert.NoError(t, err)
+	assert.Contains(t, out, "result: allowed")
+
+	out, err = runOutput(t, "verify", "--config", conf, "--scopes-config-key", "", "--scopes", "api:write", strings.TrimSpace(token))
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, out, "result: denied, forbidden: missing scopes: api:write")
+
+	// StrictRoles 时没有 roles 的 token 不会被赋予 guest 角色
+	token, err = runOutput(t, "mint", "--config", conf, "--sub", "alice")
+	assert.NoError(t, err)
+	out, err = runOutput(t, "verify", "--config", conf, strings.TrimSpace(token))
+	assert.True(t, errors.Is(err, errDenied))
+	assert.Contains(t, out, "acl:    denied, no entry matched")
+	assert.NotContains(t, out, "guest")
+}
+
+func TestRun(t *testing.T) {
+	_, err := runOutput(t)
+	assert.Equal(t, errUsage, err)
+
+	_, err = runOutput(t, "unknown")
+	assert.Error(t, err)
+
+	out, err := runOutput(t, "help")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "verify")
+
+	out, err = runOutput(t, "verify", "--help")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "--scopes")
+}
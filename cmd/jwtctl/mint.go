@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/geeksmy/go-libs/jwt"
+	"github.com/google/uuid"
+)
+
+type mintFlags struct {
+	conf confFlags
+
+	subject  string
+	issuer   string
+	audience []string
+	roles    []string
+	scopes   []string
+	orgs     []string
+	name     string
+	email    string
+	origin   string
+	meta     map[string]string
+	id       string
+	ttl      time.Duration
+}
+
+// runMint 使用 --config 中的签发密钥签发 UserClaims
+func runMint(args []string, stdout io.Writer) error {
+	f := mintFlags{}
+	flagSet := newFlagSet("mint", stdout)
+	f.conf.bind(flagSet)
+	flagSet.StringVar(&f.subject, "sub", "", "sub claim")
+	flagSet.StringVar(&f.issuer, "iss", "", "iss claim, defaults to TokenIssuer in --config")
+	flagSet.StringSliceVar(&f.audience, "aud", nil, "aud claim")
+	flagSet.StringSliceVar(&f.roles, "roles", nil, "roles claim")
+	flagSet.StringSliceVar(&f.scopes, "scopes", nil, "scopes claim")
+	flagSet.StringSliceVar(&f.orgs, "org", nil, "org claim")
+	flagSet.StringVar(&f.name, "name", "", "name claim")
+	flagSet.StringVar(&f.email, "email", "", "email claim")
+	flagSet.StringVar(&f.origin, "origin", "", "origin claim")
+	flagSet.StringToStringVar(&f.meta, "meta", nil, "meta claim, eg: --meta tenant=acme,plan=pro")
+	flagSet.StringVar(&f.id, "jti", "", "jti claim, defaults to a random uuid")
+	flagSet.DurationVar(&f.ttl, "ttl", 15*time.Minute, "token lifetime, 0 for a token without exp")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if flagSet.NArg() != 0 {
+		return fmt.Errorf("unexpected arguments: %v", flagSet.Args())
+	}
+
+	conf, err := f.conf.load()
+	if err != nil {
+		return err
+	}
+	auth, err := jwt.NewAuthenticator(conf)
+	if err != nil {
+		return err
+	}
+	defer auth.Close()
+
+	token, err := auth.Sign(f.claims(conf, time.Now()))
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, token)
+	return err
+}
+
+func (f mintFlags) claims(conf jwt.Conf, now time.Time) jwt.UserClaims {
+	claims := jwt.UserClaims{
+		ID:            f.id,
+		Subject:       f.subject,
+		Issuer:        f.issuer,
+		Audience:      f.audience,
+		IssuedAt:      now.Unix(),
+		NotBefore:     now.Unix(),
+		Name:          f.name,
+		Email:         f.email,
+		Origin:        f.origin,
+		Roles:         f.roles,
+		Scopes:        f.scopes,
+		Organizations: f.orgs,
+		MetaData:      f.meta,
+	}
+	if claims.ID == "" {
+		claims.ID = uuid.New().String()
+	}
+	if claims.Issuer == "" {
+		claims.Issuer = conf.TokenIssuer
+	}
+	if len(claims.MetaData) == 0 {
+		claims.MetaData = nil
+	}
+	if f.ttl > 0 {
+		claims.ExpiresAt = now.Add(f.ttl).Unix()
+	}
+	return claims
+}
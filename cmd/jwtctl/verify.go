@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	goalibs "github.com/geeksmy/go-libs/goa-libs"
+	"github.com/geeksmy/go-libs/jwt"
+	goa "goa.design/goa/v3/pkg"
+	"goa.design/goa/v3/security"
+)
+
+var errDenied = errors.New("token denied")
+
+type verifyFlags struct {
+	conf        confFlags
+	scopesKey   string
+	scopes      []string
+	locale      string
+	userIDClaim string
+}
+
+// runVerify 使用 --config 验证 token, 输出验签, access list 和 scopes 的检查结果
+// result 为 goalibs.JwtAuth 对同一个 token 的处理结果, 拒绝时返回 errDenied
+func runVerify(args []string, stdout io.Writer) error {
+	f := verifyFlags{}
+	flagSet := newFlagSet("verify", stdout)
+	f.conf.bind(flagSet)
+	flagSet.StringVar(&f.scopesKey, "scopes-config-key", "Scopes", "key of goalibs.ScopeConf in --config, empty for the default scope model")
+	flagSet.StringSliceVar(&f.scopes, "scopes", nil, "scopes required by the endpoint, token scopes may use wildcards like api:*")
+	flagSet.StringVar(&f.locale, "locale", jwt.DefaultLocale, "locale of error messages, eg: zh-CN, en")
+	flagSet.StringVar(&f.userIDClaim, "user-id-claim", goalibs.UserIDClaimJTI, "claim used as the current user id: jti, sub")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	token, err := readToken(flagSet.Args())
+	if err != nil {
+		return err
+	}
+
+	conf, err := f.conf.load()
+	if err != nil {
+		return err
+	}
+	scopeModel, err := f.conf.loadScopes(f.scopesKey)
+	if err != nil {
+		return err
+	}
+	auth, err := jwt.NewAuthenticator(conf)
+	if err != nil {
+		return err
+	}
+	defer auth.Close()
+
+	ctx := jwt.WithLocale(context.Background(), f.locale)
+
+	claims, verifyErr := auth.VerifyContext(ctx, token)
+	if verifyErr != nil {
+		fmt.Fprintf(stdout, "token:  invalid, %v\n", verifyErr)
+		// 验证失败时使用未验证的 claims 展示 access list 的检查结果, 和服务一样处理 StrictRoles
+		claims = unverifiedClaims(token, conf.StrictRoles)
+	} else {
+		fmt.Fprintln(stdout, "token:  valid")
+	}
+
+	if claims != nil {
+		fmt.Fprintf(stdout, "acl:    %s\n", describeDecision(auth.Evaluate(claims)))
+		fmt.Fprintf(stdout, "scopes: required [%s], granted [%s]\n",
+			strings.Join(f.scopes, ", "), strings.Join(claims.Scopes, ", "))
+	}
+
+	auther := goalibs.NewJwtAuth(auth.Validator())
+	auther.UserIDClaim = f.userIDClaim
+	auther.Scopes = scopeModel
+	ctx, err = auther.JWTAuth(ctx, token, &security.JWTScheme{RequiredScopes: f.scopes})
+	if err != nil {
+		fmt.Fprintf(stdout, "result: denied, %s\n", describeError(err))
+		return errDenied
+	}
+
+	userID, _ := auther.GetCurrentUserID(ctx)
+	fmt.Fprintf(stdout, "result: allowed, user id %s\n", userID)
+	if claims != nil {
+		return printJSON(stdout, "claims", claims)
+	}
+	return nil
+}
+
+func unverifiedClaims(token string, strictRoles bool) *jwt.UserClaims {
+	_, m, err := decodeToken(token)
+	if err != nil {
+		return nil
+	}
+	newUserClaims := jwt.NewUserClaimsFromMap
+	if strictRoles {
+		newUserClaims = jwt.NewStrictUserClaimsFromMap
+	}
+	claims, err := newUserClaims(m)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+func describeDecision(d jwt.ACLDecision) string {
+	if d.Entry == nil {
+		return "denied, no entry matched"
+	}
+
+	entry := fmt.Sprintf("#%d %q", d.Index, d.Entry.GetAction()+" "+d.Entry.GetClaim()+" "+d.Entry.GetValues())
+	result := "denied"
+	if d.Allowed {
+		result = "allowed"
+	}
+	return fmt.Sprintf("%s by %s, %s %s matches %s", result, entry, d.Entry.GetClaim(), d.Value, d.Pattern)
+}
+
+// describeError 输出 goa 错误的名字和提示, 和服务返回给客户端的内容一致
+func describeError(err error) string {
+	var serviceErr *goa.ServiceError
+	if errors.As(err, &serviceErr) {
+		return fmt.Sprintf("%s: %s", serviceErr.Name, serviceErr.Message)
+	}
+	return err.Error()
+}
@@ -0,0 +1,192 @@
+package goalibs
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/ajg/form"
+	"github.com/hashicorp/go-msgpack/codec"
+	goahttp "goa.design/goa/v3/http"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	ErrNotProtoMessage = errors.New("application/x-protobuf requires a proto.Message")
+)
+
+// EncoderFunc 创建 response body 的 encoder
+type EncoderFunc func(w io.Writer) goahttp.Encoder
+
+// DecoderFunc 创建 request body 的 decoder
+type DecoderFunc func(r io.Reader) goahttp.Decoder
+
+// codecRegistry RequestDecoder 和 ResponseEncoder 支持的 mime type
+// encoderTypes 按注册的顺序决定 */* 等通配符的优先级
+type codecRegistry struct {
+	mu           sync.RWMutex
+	encoderTypes []string
+	decoderTypes []string
+	encoders     map[string]EncoderFunc
+	decoders     map[string]DecoderFunc
+}
+
+var codecs = &codecRegistry{
+	encoders: map[string]EncoderFunc{},
+	decoders: map[string]DecoderFunc{},
+}
+
+func init() {
+	RegisterCodec("application/json",
+		func(w io.Writer) goahttp.Encoder { return json.NewEncoder(w) },
+		func(r io.Reader) goahttp.Decoder { return json.NewDecoder(r) })
+	RegisterCodec("application/xml",
+		func(w io.Writer) goahttp.Encoder { return xml.NewEncoder(w) },
+		func(r io.Reader) goahttp.Decoder { return xml.NewDecoder(r) })
+	RegisterCodec("application/gob",
+		func(w io.Writer) goahttp.Encoder { return gob.NewEncoder(w) },
+		func(r io.Reader) goahttp.Decoder { return gob.NewDecoder(r) })
+	RegisterCodec("application/x-www-form-urlencoded",
+		func(w io.Writer) goahttp.Encoder { return form.NewEncoder(w) },
+		func(r io.Reader) goahttp.Decoder { return form.NewDecoder(r) })
+
+	// msgpack 和 cbor 和 encoding/json 一样使用 json tag
+	msgpackHandle := &codec.MsgpackHandle{}
+	msgpackHandle.RawToString = true
+	msgpackHandle.WriteExt = true
+	RegisterCodec("application/msgpack",
+		func(w io.Writer) goahttp.Encoder { return codec.NewEncoder(w, msgpackHandle) },
+		func(r io.Reader) goahttp.Decoder { return codec.NewDecoder(r, msgpackHandle) })
+	cborHandle := &codec.CborHandle{}
+	RegisterCodec("application/cbor",
+		func(w io.Writer) goahttp.Encoder { return codec.NewEncoder(w, cborHandle) },
+		func(r io.Reader) goahttp.Decoder { return codec.NewDecoder(r, cborHandle) })
+	// protobuf 只能用于 proto.Message, goa 生成的 body 不是 proto.Message
+	RegisterCodec("application/x-protobuf", newProtoEncoder, newProtoDecoder)
+}
+
+// RegisterEncoder 注册 ResponseEncoder 使用的 encoder, mediaType 已经注册时替换 encoder
+// 新注册的 mime type 在通配符匹配时优先级最低, application/json 始终是默认格式
+func RegisterEncoder(mediaType string, fn EncoderFunc) {
+	mediaType = strings.ToLower(mediaType)
+
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+
+	if _, exists := codecs.encoders[mediaType]; !exists {
+		codecs.encoderTypes = append(codecs.encoderTypes, mediaType)
+	}
+	codecs.encoders[mediaType] = fn
+}
+
+// RegisterDecoder 注册 RequestDecoder 使用的 decoder, mediaType 已经注册时替换 decoder
+func RegisterDecoder(mediaType string, fn DecoderFunc) {
+	mediaType = strings.ToLower(mediaType)
+
+	codecs.mu.Lock()
+	defer codecs.mu.Unlock()
+
+	if _, exists := codecs.decoders[mediaType]; !exists {
+		codecs.decoderTypes = append(codecs.decoderTypes, mediaType)
+	}
+	codecs.decoders[mediaType] = fn
+}
+
+// RegisterCodec 同时注册 mediaType 的 encoder 和 decoder
+func RegisterCodec(mediaType string, enc EncoderFunc, dec DecoderFunc) {
+	RegisterEncoder(mediaType, enc)
+	RegisterDecoder(mediaType, dec)
+}
+
+// lookupEncoder 返回 mediaType 对应的 encoder, 没有注册时按 +json, +xml 等后缀查找
+func (r *codecRegistry) lookupEncoder(mediaType string) (EncoderFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if fn, exists := r.encoders[mediaType]; exists {
+		return fn, true
+	}
+	if suffix, ok := mediaTypeSuffix(mediaType); ok {
+		fn, exists := r.encoders[suffix]
+		return fn, exists
+	}
+	return nil, false
+}
+
+// lookupDecoder 返回 mediaType 对应的 decoder, 没有注册时按 +json, +xml 等后缀查找
+func (r *codecRegistry) lookupDecoder(mediaType string) (DecoderFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if fn, exists := r.decoders[mediaType]; exists {
+		return fn, true
+	}
+	if suffix, ok := mediaTypeSuffix(mediaType); ok {
+		fn, exists := r.decoders[suffix]
+		return fn, exists
+	}
+	return nil, false
+}
+
+// registeredEncoders 返回注册了 encoder 的 mime type
+func (r *codecRegistry) registeredEncoders() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.encoderTypes...)
+}
+
+// registeredDecoders 返回注册了 decoder 的 mime type
+func (r *codecRegistry) registeredDecoders() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]string(nil), r.decoderTypes...)
+}
+
+// mediaTypeSuffix application/vnd.api+json 返回 application/json
+func mediaTypeSuffix(mediaType string) (string, bool) {
+	i := strings.LastIndexByte(mediaType, '+')
+	if i < 0 {
+		return "", false
+	}
+	return "application/" + mediaType[i+1:], true
+}
+
+func newProtoEncoder(w io.Writer) goahttp.Encoder {
+	return goahttp.EncodingFunc(func(v interface{}) error {
+		m, ok := v.(proto.Message)
+		if !ok {
+			return ErrNotProtoMessage
+		}
+		b, err := proto.Marshal(m)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+}
+
+func newProtoDecoder(r io.Reader) goahttp.Decoder {
+	return goahttp.EncodingFunc(func(v interface{}) error {
+		m, ok := v.(proto.Message)
+		if !ok {
+			return ErrNotProtoMessage
+		}
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if len(b) == 0 {
+			// 和其他 decoder 一样, 空 body 返回 io.EOF, goa 会返回 missing payload
+			return io.EOF
+		}
+		return proto.Unmarshal(b, m)
+	})
+}
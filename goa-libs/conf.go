@@ -45,3 +45,28 @@ func BindPflag(flagSet *pflag.FlagSet, keyPrefix string) {
 	flagSet.Bool("debug", false, "Log request and response bodies")
 	_ = viper.BindPFlag(keyPrefix+".debug", flagSet.Lookup("debug"))
 }
+
+/*
+Scopes:
+    Implies:
+        admin: ["user:*", "api:*", "!api:delete"]
+        editor: ["api:read", "api:write"]
+    RequireAny: false
+    AllowRootWildcard: false
+*/
+// ScopeConf scope 的层级规则, 用于 NewScopeModelWithConf
+type ScopeConf struct {
+	// Implies 只能在配置文件中设置
+	Implies           map[string][]string
+	RequireAny        bool
+	AllowRootWildcard bool
+}
+
+// 为 ScopeConf 绑定 pflag
+func BindScopePflag(flagSet *pflag.FlagSet, keyPrefix string) {
+	flagSet.Bool("scopes-require-any", false, "Require any of the endpoint scopes instead of all")
+	_ = viper.BindPFlag(keyPrefix+".RequireAny", flagSet.Lookup("scopes-require-any"))
+
+	flagSet.Bool("scopes-allow-root-wildcard", false, "Allow token scopes like * or *:read to grant access")
+	_ = viper.BindPFlag(keyPrefix+".AllowRootWildcard", flagSet.Lookup("scopes-allow-root-wildcard"))
+}
@@ -0,0 +1,147 @@
+package goalibs
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrCredentialNotFound = errors.New("credential not found")
+)
+
+const apiKeyBytes = 32
+
+// APIKeyCredential 保存的 API key, 只保存 key 的摘要
+type APIKeyCredential struct {
+	ID string
+	// KeyHash HashAPIKey 计算的 key 摘要
+	KeyHash string
+	Subject string
+	Roles   []string
+	// Scopes 和 jwt 的 scopes 一样支持通配符, 例如 user:*
+	Scopes []string
+	// ExpiresAt 过期时间, unix 秒, 0 表示不过期
+	ExpiresAt int64
+}
+
+// BasicCredential Basic 认证的用户名和密码, 只保存 HashPassword 计算的 bcrypt 摘要
+type BasicCredential struct {
+	Username     string
+	PasswordHash string
+	// Subject 当前用户 ID, 为空时使用 Username
+	Subject string
+	Roles   []string
+	Scopes  []string
+}
+
+// CredentialStore APIKeyAuth 和 BasicAuth 查询凭证的 store
+type CredentialStore interface {
+	// FindAPIKey 按 key 摘要查询 API key, 不存在时返回 ErrCredentialNotFound
+	FindAPIKey(ctx context.Context, keyHash string) (*APIKeyCredential, error)
+	// FindBasicCredential 按用户名查询, 不存在时返回 ErrCredentialNotFound
+	FindBasicCredential(ctx context.Context, username string) (*BasicCredential, error)
+}
+
+// GenerateAPIKey 生成随机的 API key, 返回 key 明文和保存到 store 的摘要
+func GenerateAPIKey() (string, string, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", "", err
+	}
+	key := base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey 计算 API key 的摘要, API key 是随机生成的, 使用 sha256 即可
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// HashPassword 使用 bcrypt 计算密码的摘要
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// MemoryCredentialStore 基于内存的 CredentialStore, 适用于配置文件中的少量凭证和测试
+type MemoryCredentialStore struct {
+	mu      sync.RWMutex
+	apiKeys map[string]APIKeyCredential
+	users   map[string]BasicCredential
+}
+
+// NewMemoryCredentialStore returns MemoryCredentialStore instance.
+func NewMemoryCredentialStore() *MemoryCredentialStore {
+	return &MemoryCredentialStore{
+		apiKeys: map[string]APIKeyCredential{},
+		users:   map[string]BasicCredential{},
+	}
+}
+
+// PutAPIKey 保存 API key, KeyHash 相同时覆盖
+func (s *MemoryCredentialStore) PutAPIKey(cred APIKeyCredential) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.apiKeys[cred.KeyHash] = cred
+}
+
+// DeleteAPIKey 删除 API key
+func (s *MemoryCredentialStore) DeleteAPIKey(keyHash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.apiKeys, keyHash)
+}
+
+// PutBasicCredential 保存 Basic 认证的用户, Username 相同时覆盖
+func (s *MemoryCredentialStore) PutBasicCredential(cred BasicCredential) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users[cred.Username] = cred
+}
+
+// DeleteBasicCredential 删除 Basic 认证的用户
+func (s *MemoryCredentialStore) DeleteBasicCredential(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.users, username)
+}
+
+// FindAPIKey 按 key 摘要查询 API key
+func (s *MemoryCredentialStore) FindAPIKey(_ context.Context, keyHash string) (*APIKeyCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cred, exists := s.apiKeys[keyHash]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+	return &cred, nil
+}
+
+// FindBasicCredential 按用户名查询
+func (s *MemoryCredentialStore) FindBasicCredential(_ context.Context, username string) (*BasicCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cred, exists := s.users[username]
+	if !exists {
+		return nil, ErrCredentialNotFound
+	}
+	return &cred, nil
+}
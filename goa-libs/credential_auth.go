@@ -0,0 +1,127 @@
+package goalibs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/geeksmy/go-libs/jwt"
+	"github.com/geeksmy/go-libs/jwt/goajwt"
+	"goa.design/goa/v3/security"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// dummyPasswordHash 用户不存在时同样比较一次 bcrypt, 避免通过响应时间判断用户是否存在
+	dummyPasswordHash     []byte
+	dummyPasswordHashOnce sync.Once
+)
+
+// APIKeyAuth goa 的 API key 认证, 认证通过后和 JwtAuth 一样在 ctx 中保存 claims 和当前用户 ID
+// 当前用户 ID 为 APIKeyCredential.Subject, 对应 JwtAuth 的 UserIDClaimSub
+//
+//	store := goalibs.NewMemoryCredentialStore()
+//	store.PutAPIKey(goalibs.APIKeyCredential{KeyHash: goalibs.HashAPIKey(key), Subject: "ci", Scopes: []string{"api:read"}})
+//	auther := goalibs.NewAPIKeyAuth(store)
+type APIKeyAuth struct {
+	Store CredentialStore
+	// Scopes 检查 scopes 使用的 ScopeModel, 为 nil 时使用 DefaultScopeModel
+	Scopes *ScopeModel
+}
+
+// NewAPIKeyAuth returns APIKeyAuth instance.
+func NewAPIKeyAuth(store CredentialStore) *APIKeyAuth {
+	return &APIKeyAuth{
+		Store:  store,
+		Scopes: DefaultScopeModel,
+	}
+}
+
+// APIKeyAuth API key 认证
+func (a *APIKeyAuth) APIKeyAuth(ctx context.Context, key string, scheme *security.APIKeyScheme) (context.Context, error) {
+	if key == "" {
+		return ctx, goajwt.UnauthorizedErr("invalid api key")
+	}
+
+	cred, err := a.Store.FindAPIKey(ctx, HashAPIKey(key))
+	if errors.Is(err, ErrCredentialNotFound) {
+		return ctx, goajwt.UnauthorizedErr("invalid api key")
+	}
+	if err != nil {
+		return ctx, err
+	}
+	if cred.ExpiresAt > 0 && cred.ExpiresAt < time.Now().Unix() {
+		return ctx, goajwt.UnauthorizedErr("api key expired")
+	}
+
+	if scheme != nil {
+		if err := checkScopes(a.Scopes, scheme.RequiredScopes, cred.Scopes); err != nil {
+			return ctx, err
+		}
+	}
+
+	claims := &jwt.UserClaims{
+		ID:        cred.ID,
+		Subject:   cred.Subject,
+		Roles:     cred.Roles,
+		Scopes:    cred.Scopes,
+		ExpiresAt: cred.ExpiresAt,
+	}
+	return contextWithPrincipal(ctx, cred.Subject, claims, a.Scopes), nil
+}
+
+// BasicAuth goa 的 Basic 认证, 认证通过后和 JwtAuth 一样在 ctx 中保存 claims 和当前用户 ID
+// 当前用户 ID 为 BasicCredential.Subject, 为空时使用 Username, 对应 JwtAuth 的 UserIDClaimSub
+type BasicAuth struct {
+	Store CredentialStore
+	// Scopes 检查 scopes 使用的 ScopeModel, 为 nil 时使用 DefaultScopeModel
+	Scopes *ScopeModel
+}
+
+// NewBasicAuth returns BasicAuth instance.
+func NewBasicAuth(store CredentialStore) *BasicAuth {
+	return &BasicAuth{
+		Store:  store,
+		Scopes: DefaultScopeModel,
+	}
+}
+
+// BasicAuth Basic 认证
+func (a *BasicAuth) BasicAuth(ctx context.Context, user, pass string, scheme *security.BasicScheme) (context.Context, error) {
+	cred, err := a.Store.FindBasicCredential(ctx, user)
+	if errors.Is(err, ErrCredentialNotFound) {
+		_ = bcrypt.CompareHashAndPassword(getDummyPasswordHash(), []byte(pass))
+		return ctx, goajwt.UnauthorizedErr("invalid username or password")
+	}
+	if err != nil {
+		return ctx, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(cred.PasswordHash), []byte(pass)) != nil {
+		return ctx, goajwt.UnauthorizedErr("invalid username or password")
+	}
+
+	if scheme != nil {
+		if err := checkScopes(a.Scopes, scheme.RequiredScopes, cred.Scopes); err != nil {
+			return ctx, err
+		}
+	}
+
+	subject := cred.Subject
+	if subject == "" {
+		subject = cred.Username
+	}
+	claims := &jwt.UserClaims{
+		Subject: subject,
+		Roles:   cred.Roles,
+		Scopes:  cred.Scopes,
+	}
+	return contextWithPrincipal(ctx, subject, claims, a.Scopes), nil
+}
+
+func getDummyPasswordHash() []byte {
+	dummyPasswordHashOnce.Do(func() {
+		dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	return dummyPasswordHash
+}
@@ -0,0 +1,169 @@
+package goalibs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	gormutil "github.com/geeksmy/go-libs/gormutil_v2"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEmptyAPIKeyID                = errors.New("empty api key id")
+	ErrEmptyBasicCredentialUsername = errors.New("empty basic credential username")
+)
+
+// APIKeyModel GormCredentialStore 保存 API key 的表, roles 和 scopes 使用空格分隔
+type APIKeyModel struct {
+	ID        string `gorm:"primaryKey;size:64"`
+	KeyHash   string `gorm:"uniqueIndex;size:64;not null"`
+	Subject   string `gorm:"size:255;not null"`
+	Roles     string `gorm:"size:1024"`
+	Scopes    string `gorm:"size:1024"`
+	ExpiresAt int64
+	CreatedAt time.Time
+}
+
+// TableName 表名
+func (APIKeyModel) TableName() string {
+	return "api_keys"
+}
+
+// BeforeCreate 拒绝没有 ID 的 API key, ID 是主键, 为空时只能保存一个 API key
+func (m *APIKeyModel) BeforeCreate(*gorm.DB) error {
+	if m.ID == "" {
+		return ErrEmptyAPIKeyID
+	}
+	return nil
+}
+
+// BasicCredentialModel GormCredentialStore 保存 Basic 认证用户的表, roles 和 scopes 使用空格分隔
+type BasicCredentialModel struct {
+	Username     string `gorm:"primaryKey;size:255"`
+	PasswordHash string `gorm:"size:255;not null"`
+	Subject      string `gorm:"size:255"`
+	Roles        string `gorm:"size:1024"`
+	Scopes       string `gorm:"size:1024"`
+	CreatedAt    time.Time
+}
+
+// TableName 表名
+func (BasicCredentialModel) TableName() string {
+	return "basic_credentials"
+}
+
+// BeforeCreate 拒绝没有用户名的用户
+func (m *BasicCredentialModel) BeforeCreate(*gorm.DB) error {
+	if m.Username == "" {
+		return ErrEmptyBasicCredentialUsername
+	}
+	return nil
+}
+
+// GormCredentialStore 基于 gormutil 的 CredentialStore, 需要先调用 gormutil.ConnectGlobalDB
+//
+//	store := goalibs.NewGormCredentialStore(nil)
+//	_ = store.AutoMigrate()
+type GormCredentialStore struct {
+	// DB 为 nil 时使用 gormutil.DB, 为了 UnitTest 可以 mock
+	DB func() *gorm.DB
+}
+
+// NewGormCredentialStore returns GormCredentialStore instance, db 为 nil 时使用 gormutil.DB
+func NewGormCredentialStore(db func() *gorm.DB) *GormCredentialStore {
+	return &GormCredentialStore{DB: db}
+}
+
+func (s *GormCredentialStore) db(ctx context.Context) *gorm.DB {
+	if s.DB != nil {
+		return s.DB().WithContext(ctx)
+	}
+	return gormutil.DB().WithContext(ctx)
+}
+
+// AutoMigrate 创建 api_keys 和 basic_credentials 表
+func (s *GormCredentialStore) AutoMigrate() error {
+	return s.db(context.Background()).AutoMigrate(&APIKeyModel{}, &BasicCredentialModel{})
+}
+
+// SaveAPIKey 保存 API key
+func (s *GormCredentialStore) SaveAPIKey(ctx context.Context, cred APIKeyCredential) error {
+	m := APIKeyModel{
+		ID:        cred.ID,
+		KeyHash:   cred.KeyHash,
+		Subject:   cred.Subject,
+		Roles:     strings.Join(cred.Roles, " "),
+		Scopes:    strings.Join(cred.Scopes, " "),
+		ExpiresAt: cred.ExpiresAt,
+	}
+	return s.db(ctx).Create(&m).Error
+}
+
+// DeleteAPIKey 按 ID 删除 API key
+func (s *GormCredentialStore) DeleteAPIKey(ctx context.Context, id string) error {
+	return s.db(ctx).Scopes(whereColumn("id", id)).Delete(&APIKeyModel{}).Error
+}
+
+// SaveBasicCredential 保存 Basic 认证的用户
+func (s *GormCredentialStore) SaveBasicCredential(ctx context.Context, cred BasicCredential) error {
+	m := BasicCredentialModel{
+		Username:     cred.Username,
+		PasswordHash: cred.PasswordHash,
+		Subject:      cred.Subject,
+		Roles:        strings.Join(cred.Roles, " "),
+		Scopes:       strings.Join(cred.Scopes, " "),
+	}
+	return s.db(ctx).Create(&m).Error
+}
+
+// FindAPIKey 按 key 摘要查询 API key
+func (s *GormCredentialStore) FindAPIKey(ctx context.Context, keyHash string) (*APIKeyCredential, error) {
+	var m APIKeyModel
+	err := s.db(ctx).Scopes(whereColumn("key_hash", keyHash)).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &APIKeyCredential{
+		ID:        m.ID,
+		KeyHash:   m.KeyHash,
+		Subject:   m.Subject,
+		Roles:     strings.Fields(m.Roles),
+		Scopes:    strings.Fields(m.Scopes),
+		ExpiresAt: m.ExpiresAt,
+	}
+	return cred, nil
+}
+
+// FindBasicCredential 按用户名查询
+func (s *GormCredentialStore) FindBasicCredential(ctx context.Context, username string) (*BasicCredential, error) {
+	var m BasicCredentialModel
+	err := s.db(ctx).Scopes(whereColumn("username", username)).First(&m).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &BasicCredential{
+		Username:     m.Username,
+		PasswordHash: m.PasswordHash,
+		Subject:      m.Subject,
+		Roles:        strings.Fields(m.Roles),
+		Scopes:       strings.Fields(m.Scopes),
+	}
+	return cred, nil
+}
+
+// whereColumn 按列精确查询的 Scope
+func whereColumn(column, value string) gormutil.Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where(column+" = ?", value)
+	}
+}
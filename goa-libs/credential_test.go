@@ -0,0 +1,160 @@
+package goalibs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gormutil "github.com/geeksmy/go-libs/gormutil_v2"
+	"github.com/stretchr/testify/assert"
+	goa "goa.design/goa/v3/pkg"
+	"goa.design/goa/v3/security"
+	"gorm.io/gorm"
+)
+
+func isUnauthorized(err error) bool {
+	var serviceErr *goa.ServiceError
+	return errors.As(err, &serviceErr) && serviceErr.Name == "unauthorized"
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	key, keyHash, err := GenerateAPIKey()
+	assert.NoError(t, err)
+	assert.Equal(t, HashAPIKey(key), keyHash)
+
+	expiredKey, expiredHash, err := GenerateAPIKey()
+	assert.NoError(t, err)
+
+	store := NewMemoryCredentialStore()
+	store.PutAPIKey(APIKeyCredential{
+		ID:      "key-1",
+		KeyHash: keyHash,
+		Subject: userID,
+		Roles:   []string{"ci"},
+		Scopes:  []string{"api:*"},
+	})
+	store.PutAPIKey(APIKeyCredential{
+		ID:        "key-2",
+		KeyHash:   expiredHash,
+		Subject:   userID,
+		Scopes:    []string{"api:*"},
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	})
+
+	auther := NewAPIKeyAuth(store)
+	scheme := &security.APIKeyScheme{RequiredScopes: scopes}
+
+	ctx, err := auther.APIKeyAuth(context.Background(), key, scheme)
+	assert.NoError(t, err)
+	id, err := CurrentUserID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, id)
+	claims, ok := ClaimsFromContext(ctx)
+	if assert.True(t, ok) {
+		assert.Equal(t, "key-1", claims.ID)
+	}
+	assert.True(t, HasRole(ctx, "ci"))
+	assert.True(t, HasScope(ctx, "api:delete"))
+
+	_, err = auther.APIKeyAuth(context.Background(), key, &security.APIKeyScheme{RequiredScopes: []string{"user:read"}})
+	assert.Error(t, err)
+	assert.False(t, isUnauthorized(err))
+
+	for _, k := range []string{"", "unknown", keyHash, expiredKey} {
+		_, err = auther.APIKeyAuth(context.Background(), k, scheme)
+		assert.True(t, isUnauthorized(err), "%q: %v", k, err)
+	}
+
+	store.DeleteAPIKey(keyHash)
+	_, err = auther.APIKeyAuth(context.Background(), key, scheme)
+	assert.True(t, isUnauthorized(err))
+}
+
+func TestBasicAuth(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	assert.NoError(t, err)
+
+	store := NewMemoryCredentialStore()
+	store.PutBasicCredential(BasicCredential{
+		Username:     "alice",
+		PasswordHash: hash,
+		Roles:        []string{"editor"},
+		Scopes:       []string{"api:read", "api:write"},
+	})
+
+	auther := NewBasicAuth(store)
+	scheme := &security.BasicScheme{RequiredScopes: scopes}
+
+	// Subject 为空时使用 Username
+	ctx, err := auther.BasicAuth(context.Background(), "alice", "s3cret", scheme)
+	assert.NoError(t, err)
+	id, err := CurrentUserID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", id)
+	assert.Equal(t, []string{"editor"}, RolesFromContext(ctx))
+	assert.Equal(t, []string{"api:read", "api:write"}, ScopesFromContext(ctx))
+
+	_, err = auther.BasicAuth(context.Background(), "alice", "wrong", scheme)
+	assert.True(t, isUnauthorized(err))
+	_, err = auther.BasicAuth(context.Background(), "bob", "s3cret", scheme)
+	assert.True(t, isUnauthorized(err))
+
+	_, err = auther.BasicAuth(context.Background(), "alice", "s3cret", &security.BasicScheme{RequiredScopes: []string{"api:delete"}})
+	assert.Error(t, err)
+	assert.False(t, isUnauthorized(err))
+
+	store.PutBasicCredential(BasicCredential{Username: "alice", PasswordHash: hash, Subject: userID})
+	ctx, err = auther.BasicAuth(context.Background(), "alice", "s3cret", nil)
+	assert.NoError(t, err)
+	id, _ = CurrentUserID(ctx)
+	assert.Equal(t, userID, id)
+}
+
+func TestGormCredentialStore(t *testing.T) {
+	sqlDB, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer sqlDB.Close()
+
+	// 没有设置 DB 时使用 gormutil.DB
+	db := gormutil.MockedGORMDBForTest(t, sqlDB)
+	origDB := gormutil.DB
+	gormutil.DB = func() *gorm.DB { return db }
+	defer func() { gormutil.DB = origDB }()
+	store := NewGormCredentialStore(nil)
+
+	keyHash := HashAPIKey("key")
+	mock.ExpectQuery(`SELECT \* FROM "api_keys" WHERE key_hash = \$1`).
+		WithArgs(keyHash).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "key_hash", "subject", "roles", "scopes", "expires_at"}).
+			AddRow("key-1", keyHash, userID, "ci", "api:read api:write", 0))
+	cred, err := store.FindAPIKey(context.Background(), keyHash)
+	assert.NoError(t, err)
+	assert.Equal(t, &APIKeyCredential{
+		ID:      "key-1",
+		KeyHash: keyHash,
+		Subject: userID,
+		Roles:   []string{"ci"},
+		Scopes:  []string{"api:read", "api:write"},
+	}, cred)
+
+	mock.ExpectQuery(`SELECT \* FROM "basic_credentials" WHERE username = \$1`).
+		WithArgs("bob").
+		WillReturnRows(sqlmock.NewRows([]string{"username"}))
+	_, err = store.FindBasicCredential(context.Background(), "bob")
+	assert.Equal(t, ErrCredentialNotFound, err)
+
+	// ID 和用户名是主键, 为空时不写入数据库
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	err = store.SaveAPIKey(context.Background(), APIKeyCredential{KeyHash: keyHash, Subject: userID})
+	assert.True(t, errors.Is(err, ErrEmptyAPIKeyID), "%v", err)
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	err = store.SaveBasicCredential(context.Background(), BasicCredential{PasswordHash: "hash"})
+	assert.True(t, errors.Is(err, ErrEmptyBasicCredentialUsername), "%v", err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,14 +1,19 @@
 package goalibs
 
 import (
-	"encoding/gob"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
+	"fmt"
 	"mime"
 	"net/http"
+	"strings"
 
-	"github.com/ajg/form"
 	goahttp "goa.design/goa/v3/http"
+	goa "goa.design/goa/v3/pkg"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
 )
 
 // RequestDecoder returns a HTTP request body decoder suitable for the given
@@ -17,32 +22,69 @@ import (
 //     * application/json using package encoding/json
 //     * application/xml using package encoding/xml
 //     * application/gob using package encoding/gob
+//     * application/x-www-form-urlencoded using package github.com/ajg/form
+//     * application/msgpack and application/cbor using package github.com/hashicorp/go-msgpack/codec
+//     * application/x-protobuf using package google.golang.org/protobuf/proto
+//     * any mime type added with RegisterDecoder or RegisterCodec
 //
 // RequestDecoder defaults to the JSON decoder if the request "Content-Type"
 // header does not match any of the supported mime type or is missing
 // altogether.
 func RequestDecoder(r *http.Request) goahttp.Decoder {
-	contentType := r.Header.Get("Content-Type")
-	if contentType == "" {
+	dec, err := requestDecoder(r)
+	if err != nil {
 		// default to JSON
-		contentType = "application/json"
-	} else {
-		// sanitize
-		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
-			contentType = mediaType
+		return json.NewDecoder(r.Body)
+	}
+	return dec
+}
+
+// StrictRequestDecoder 和 RequestDecoder 相同, 但是 "Content-Type" 不支持时不会使用 JSON,
+// Decode 返回 ErrUnsupportedMediaType. 没有 "Content-Type" 时仍然使用 JSON.
+// 单独使用时 goa 生成的代码会把 Decode 的错误转换为 400 decode_payload, 不会返回 415;
+// 需要返回 415 时在外层同时使用 StrictContentType 中间件.
+func StrictRequestDecoder(r *http.Request) goahttp.Decoder {
+	dec, err := requestDecoder(r)
+	if err != nil {
+		return goahttp.EncodingFunc(func(interface{}) error { return err })
+	}
+	return dec
+}
+
+// StrictContentType 请求有 body 并且 "Content-Type" 不支持时直接返回 415, 不执行 handler
+func StrictContentType(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength != 0 {
+			mediaType := requestMediaType(r)
+			if _, exists := codecs.lookupDecoder(mediaType); !exists {
+				err := goa.PermanentError("unsupported_media_type", "%s is not supported, supported: %s",
+					mediaType, strings.Join(codecs.registeredDecoders(), ", "))
+				writeErrorResponse(w, http.StatusUnsupportedMediaType, err)
+				return
+			}
 		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func requestDecoder(r *http.Request) (goahttp.Decoder, error) {
+	mediaType := requestMediaType(r)
+	fn, exists := codecs.lookupDecoder(mediaType)
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
 	}
+	return fn(r.Body), nil
+}
 
-	switch contentType {
-	case "application/json":
-		return json.NewDecoder(r.Body)
-	case "application/gob":
-		return gob.NewDecoder(r.Body)
-	case "application/xml":
-		return xml.NewDecoder(r.Body)
-	case "application/x-www-form-urlencoded":
-		return form.NewDecoder(r.Body)
-	default:
-		return json.NewDecoder(r.Body)
+// requestMediaType 返回 "Content-Type" 的 mime type, 没有时为 application/json
+func requestMediaType(r *http.Request) string {
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return defaultMediaType
+	}
+	// sanitize
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mediaType
 	}
+	return strings.ToLower(contentType)
 }
@@ -0,0 +1,147 @@
+package goalibs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	goahttp "goa.design/goa/v3/http"
+	goa "goa.design/goa/v3/pkg"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func newBodyRequest(contentType string, body []byte) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+func TestRequestDecoder(t *testing.T) {
+	// 使用 ResponseEncoder 编码, RequestDecoder 解码
+	for _, mediaType := range []string{
+		"application/json",
+		"application/xml",
+		"application/gob",
+		"application/msgpack",
+		"application/cbor",
+		"application/vnd.api+json",
+	} {
+		ctx := context.WithValue(context.Background(), goahttp.AcceptTypeKey, mediaType)
+		w := encodeTestResponse(ctx)
+		assert.Equal(t, mediaType, w.Header().Get("Content-Type"))
+
+		var res testResponse
+		r := newBodyRequest(mediaType+"; charset=utf-8", w.Body.Bytes())
+		assert.NoError(t, RequestDecoder(r).Decode(&res), mediaType)
+		assert.Equal(t, "alice", res.Name, mediaType)
+	}
+
+	// 没有 Content-Type 或者不支持时使用 JSON
+	for _, contentType := range []string{"", "text/plain", "invalid;;"} {
+		var res testResponse
+		r := newBodyRequest(contentType, []byte(`{"name":"bob"}`))
+		assert.NoError(t, RequestDecoder(r).Decode(&res), contentType)
+		assert.Equal(t, "bob", res.Name)
+	}
+}
+
+func TestRequestDecoder_Protobuf(t *testing.T) {
+	body, err := proto.Marshal(wrapperspb.String("alice"))
+	assert.NoError(t, err)
+
+	var msg wrapperspb.StringValue
+	assert.NoError(t, RequestDecoder(newBodyRequest("application/x-protobuf", body)).Decode(&msg))
+	assert.Equal(t, "alice", msg.GetValue())
+
+	var res testResponse
+	err = RequestDecoder(newBodyRequest("application/x-protobuf", body)).Decode(&res)
+	assert.Equal(t, ErrNotProtoMessage, err)
+	err = RequestDecoder(newBodyRequest("application/x-protobuf", nil)).Decode(&msg)
+	assert.Equal(t, io.EOF, err)
+
+	w := httptest.NewRecorder()
+	ctx := context.WithValue(context.Background(), goahttp.AcceptTypeKey, "application/x-protobuf")
+	assert.NoError(t, ResponseEncoder(ctx, w).Encode(wrapperspb.String("bob")))
+	assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &msg))
+	assert.Equal(t, "bob", msg.GetValue())
+}
+
+func TestStrictRequestDecoder(t *testing.T) {
+	var res testResponse
+	err := StrictRequestDecoder(newBodyRequest("text/plain", []byte(`{"name":"bob"}`))).Decode(&res)
+	assert.True(t, errors.Is(err, ErrUnsupportedMediaType))
+	assert.EqualError(t, err, "unsupported media type: text/plain")
+
+	assert.NoError(t, StrictRequestDecoder(newBodyRequest("", []byte(`{"name":"bob"}`))).Decode(&res))
+	assert.Equal(t, "bob", res.Name)
+
+	RegisterDecoder("text/plain", func(r io.Reader) goahttp.Decoder {
+		return goahttp.EncodingFunc(func(v interface{}) error {
+			b, err := ioutil.ReadAll(r)
+			v.(*testResponse).Name = strings.TrimSpace(string(b))
+			return err
+		})
+	})
+	defer func() {
+		codecs.mu.Lock()
+		delete(codecs.decoders, "text/plain")
+		codecs.decoderTypes = codecs.decoderTypes[:len(codecs.decoderTypes)-1]
+		codecs.mu.Unlock()
+	}()
+	assert.NoError(t, StrictRequestDecoder(newBodyRequest("text/plain", []byte("carol\n"))).Decode(&res))
+	assert.Equal(t, "carol", res.Name)
+}
+
+func TestStrictContentType(t *testing.T) {
+	called := false
+	handler := StrictContentType(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newBodyRequest("text/csv", []byte("name\nalice\n")))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"unsupported_media_type"`)
+	assert.False(t, called)
+
+	// 没有 body 的请求不检查 Content-Type
+	handler.ServeHTTP(httptest.NewRecorder(), newBodyRequest("text/csv", nil))
+	assert.True(t, called)
+
+	called = false
+	handler.ServeHTTP(httptest.NewRecorder(), newBodyRequest("application/msgpack", []byte{0x80}))
+	assert.True(t, called)
+}
+
+func TestStrictRequestDecoder_StatusCode(t *testing.T) {
+	// 和 goa 生成的 handler 相同: Decode 失败时返回 decode_payload 错误
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var res testResponse
+		if err := StrictRequestDecoder(r).Decode(&res); err != nil {
+			encodeError := goahttp.ErrorEncoder(ResponseEncoder, nil)
+			_ = encodeError(r.Context(), w, goa.DecodePayloadError(err.Error()))
+		}
+	})
+
+	// 只使用 StrictRequestDecoder 时返回 400
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, newBodyRequest("text/csv", []byte("name\nalice\n")))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"decode_payload"`)
+
+	// 同时使用 StrictContentType 中间件时返回 415
+	w = httptest.NewRecorder()
+	StrictContentType(handler).ServeHTTP(w, newBodyRequest("text/csv", []byte("name\nalice\n")))
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"unsupported_media_type"`)
+}
@@ -3,11 +3,9 @@ package goalibs
 import (
 	"context"
 	"errors"
-	"fmt"
-	"strings"
 
 	"github.com/geeksmy/go-libs/jwt"
-	"github.com/geeksmy/go-libs/util"
+	"github.com/geeksmy/go-libs/jwt/goajwt"
 	"goa.design/goa/v3/security"
 )
 
@@ -16,39 +14,102 @@ type CtxKey int
 const (
 	CurrentUserIDKey CtxKey = 999
 	JwtClaimsKey     CtxKey = 1000
+	ScopeModelKey    CtxKey = 1001
 )
 
 var (
 	ErrorUnauthorized = errors.New("请登录后再试")
 )
 
+// 当前用户 ID 使用的 claim
+const (
+	UserIDClaimSub = "sub"
+	UserIDClaimJTI = "jti"
+)
+
+// JwtAuth goa 的 jwt 认证, 验证通过后在 ctx 中保存 claims 和当前用户 ID
+//
+//	auther := goalibs.NewJwtAuth(authenticator.Validator())
+//	endpoints.Use(...)
+//	userID, err := auther.GetCurrentUserID(ctx)
 type JwtAuth struct {
+	// Validator 验签使用的 Validator, 为 nil 时使用 jwt.NewValidator()
+	// 实现了 jwt.ContextValidator 时使用 VerifyContext, 否则使用 Verify
+	Validator jwt.Validator
+	// UserIDClaim 当前用户 ID 使用的 claim: jti, sub, 为空时和之前的版本一样使用 jti
+	UserIDClaim string
+	// Scopes 检查 scopes 使用的 ScopeModel, 为 nil 时使用 DefaultScopeModel
+	Scopes *ScopeModel
+}
+
+// NewJwtAuth returns JwtAuth instance, validator 为 nil 时使用 Init 初始化的 jwt.NewValidator()
+func NewJwtAuth(validator jwt.Validator) *JwtAuth {
+	if validator == nil {
+		validator = jwt.NewValidator()
+	}
+	return &JwtAuth{
+		Validator:   validator,
+		UserIDClaim: UserIDClaimJTI,
+		Scopes:      DefaultScopeModel,
+	}
 }
 
 // JWT 认证
 func (j *JwtAuth) JWTAuth(ctx context.Context, token string, scheme *security.JWTScheme) (context.Context, error) {
-	// parse && verify JWT token,
-	validator := jwt.NewValidator()
-
-	// 1. parse JWT token,
-	userClaims, err := validator.Verify(token, scheme)
+	// 1. parse JWT token, 错误提示的语言由 jwt.LocaleResolver 从 ctx 中选择
+	userClaims, err := jwt.VerifyWith(ctx, j.validator(), token)
 	if err != nil {
-		return ctx, err
+		return ctx, goajwt.ErrorContext(ctx, err)
 	}
 
 	// 2. validate provided "scopes" claim
-	if err := validateScopes(scheme.RequiredScopes, userClaims.Scopes); err != nil {
-		return ctx, err
+	if scheme != nil {
+		if err := checkScopes(j.Scopes, scheme.RequiredScopes, userClaims.Scopes); err != nil {
+			return ctx, err
+		}
 	}
 
-	ctx = context.WithValue(ctx, CurrentUserIDKey, userClaims.ID)
-	ctx = context.WithValue(ctx, JwtClaimsKey, userClaims)
+	return contextWithPrincipal(ctx, j.userID(userClaims), userClaims, j.Scopes), nil
+}
 
-	return ctx, nil
+// contextWithPrincipal 在 ctx 中保存当前用户 ID, claims 和检查 scopes 使用的 ScopeModel
+// JWTAuth, APIKeyAuth 和 BasicAuth 保存的格式相同, 当前用户 ID 的来源不同:
+//   - JwtAuth 按 UserIDClaim 使用 jti 或者 sub, 默认 jti 和之前的版本一致
+//   - APIKeyAuth 使用 APIKeyCredential.Subject, 不是 API key 的 ID
+//   - BasicAuth 使用 BasicCredential.Subject, 为空时使用 Username
+//
+// 同一个服务混用 JwtAuth 和其他认证方式时, JwtAuth 应该设置 UserIDClaim 为 sub, CurrentUserID 才能返回同一种 ID
+func contextWithPrincipal(ctx context.Context, userID string, claims *jwt.UserClaims, scopes *ScopeModel) context.Context {
+	if userID != "" {
+		ctx = context.WithValue(ctx, CurrentUserIDKey, userID)
+	}
+	if scopes != nil {
+		ctx = context.WithValue(ctx, ScopeModelKey, scopes)
+	}
+	return context.WithValue(ctx, JwtClaimsKey, claims)
+}
+
+func (j *JwtAuth) validator() jwt.Validator {
+	if j.Validator != nil {
+		return j.Validator
+	}
+	return jwt.NewValidator()
+}
+
+func (j *JwtAuth) userID(claims *jwt.UserClaims) string {
+	if j.UserIDClaim == UserIDClaimSub {
+		return claims.Subject
+	}
+	return claims.ID
 }
 
 // 获取当前登录用户ID
 func (j *JwtAuth) GetCurrentUserID(ctx context.Context) (string, error) {
+	return CurrentUserID(ctx)
+}
+
+// CurrentUserID 返回认证时保存在 ctx 中的当前用户 ID, JWTAuth 默认为 jti
+func CurrentUserID(ctx context.Context) (string, error) {
 	userID, ok := ctx.Value(CurrentUserIDKey).(string)
 	if !ok {
 		return "", ErrorUnauthorized
@@ -56,25 +117,54 @@ func (j *JwtAuth) GetCurrentUserID(ctx context.Context) (string, error) {
 	return userID, nil
 }
 
-// 范围验证，支持使用通配符匹配
-// 比如 user:*, *:read, *, *:delete
-func validateScopes(expected, actual []string) error {
-	var missing []string
-	for _, r := range expected {
-		found := false
-		for _, s := range actual {
-			// if s == r {
-			if util.Glob(s, r) {
-				found = true
-				break
+// ClaimsFromContext 返回认证时保存在 ctx 中的 claims, APIKeyAuth 和 BasicAuth 使用凭证中的 sub, roles 和 scopes
+func ClaimsFromContext(ctx context.Context) (*jwt.UserClaims, bool) {
+	claims, ok := ctx.Value(JwtClaimsKey).(*jwt.UserClaims)
+	return claims, ok && claims != nil
+}
+
+// RolesFromContext 返回当前用户的 roles, 没有认证时返回 nil
+func RolesFromContext(ctx context.Context) []string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.Roles
+	}
+	return nil
+}
+
+// ScopesFromContext 返回当前用户的 scopes, 没有认证时返回 nil
+func ScopesFromContext(ctx context.Context) []string {
+	if claims, ok := ClaimsFromContext(ctx); ok {
+		return claims.Scopes
+	}
+	return nil
+}
+
+// HasScope 检查当前用户是否有全部 scopes, 使用认证时的 ScopeModel, 例如 user:* 包含 user:read
+func HasScope(ctx context.Context, scopes ...string) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return scopeModelFromContext(ctx).CheckAll(scopes, claims.Scopes) == nil
+}
+
+// HasAnyScope 检查当前用户是否有 scopes 中的任意一个
+func HasAnyScope(ctx context.Context, scopes ...string) bool {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return false
+	}
+	return scopeModelFromContext(ctx).CheckAny(scopes, claims.Scopes) == nil
+}
+
+// HasRole 检查当前用户是否有 roles 中的任意一个
+func HasRole(ctx context.Context, roles ...string) bool {
+	for _, role := range RolesFromContext(ctx) {
+		for _, r := range roles {
+			if role == r {
+				return true
 			}
 		}
-		if !found {
-			missing = append(missing, r)
-		}
-	}
-	if len(missing) == 0 {
-		return nil
 	}
-	return fmt.Errorf("missing scopes: %s", strings.Join(missing, ", "))
+	return false
 }
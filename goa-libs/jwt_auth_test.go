@@ -2,6 +2,7 @@ package goalibs
 
 import (
 	"context"
+	"runtime"
 	"testing"
 	"time"
 
@@ -88,6 +89,136 @@ func TestSignerImpl_Expired(t *testing.T) {
 	assert.Error(t, err2)
 }
 
+// countingValidator 记录 VerifyContext 的调用次数
+type countingValidator struct {
+	jwt.ContextValidator
+	calls int
+}
+
+func (v *countingValidator) VerifyContext(ctx context.Context, token string) (*jwt.UserClaims, error) {
+	v.calls++
+	return v.ContextValidator.VerifyContext(ctx, token)
+}
+
+func newTestAuthenticator(t *testing.T) *jwt.Authenticator {
+	auth, err := jwt.NewAuthenticator(jwt.Conf{
+		RSAPrivateKey: rsaKeyPair1[0],
+		AccessList:    []string{"allow roles editor viewer"},
+	})
+	assert.NoError(t, err)
+	return auth
+}
+
+func TestJwtAuth_Validator(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	defer auth.Close()
+
+	validator := &countingValidator{ContextValidator: auth.Validator()}
+	auther := NewJwtAuth(validator)
+	assert.Equal(t, UserIDClaimJTI, auther.UserIDClaim)
+
+	token, _, err := auth.Issue("alice", []string{"editor"}, scopes)
+	assert.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err := auther.JWTAuth(context.Background(), token, newScheme())
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 3, validator.calls)
+	assert.Equal(t, 1, auth.Cache().Len())
+
+	// 没有 scheme 时只验证 token
+	_, err = auther.JWTAuth(context.Background(), token, nil)
+	assert.NoError(t, err)
+}
+
+func TestJwtAuth_VerifyOnlyValidator(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	defer auth.Close()
+
+	// 只实现了 Verify 的 Validator 仍然可以使用
+	auther := NewJwtAuth(struct{ jwt.Validator }{auth.Validator()})
+	token, _, err := auth.Issue("alice", []string{"editor"}, scopes)
+	assert.NoError(t, err)
+
+	ctx, err := auther.JWTAuth(context.Background(), token, newScheme())
+	assert.NoError(t, err)
+	claims, ok := ClaimsFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", claims.Subject)
+
+	_, err = auther.JWTAuth(context.Background(), "invalid", newScheme())
+	assert.Error(t, err)
+}
+
+func TestJwtAuth_NoGoroutineLeak(t *testing.T) {
+	setupJwt()
+
+	token, err := jwt.NewSigner().Sign(newValidClaims())
+	assert.NoError(t, err)
+
+	auther := JwtAuth{}
+	_, err = auther.JWTAuth(context.Background(), token, newScheme())
+	assert.NoError(t, err)
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < 100; i++ {
+		_, err := auther.JWTAuth(context.Background(), token, newScheme())
+		assert.NoError(t, err)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before+2)
+}
+
+func TestJwtAuth_ContextHelpers(t *testing.T) {
+	auth := newTestAuthenticator(t)
+	defer auth.Close()
+
+	ctx := context.Background()
+	_, err := CurrentUserID(ctx)
+	assert.Equal(t, ErrorUnauthorized, err)
+	_, ok := ClaimsFromContext(ctx)
+	assert.False(t, ok)
+	assert.Nil(t, RolesFromContext(ctx))
+	assert.False(t, HasScope(ctx, "api:read"))
+	assert.False(t, HasRole(ctx, "editor"))
+
+	token, _, err := auth.Issue(userID, []string{"editor", "viewer"}, []string{"api:*", "user:read"})
+	assert.NoError(t, err)
+
+	auther := NewJwtAuth(auth.Validator())
+	ctx, err = auther.JWTAuth(context.Background(), token, newScheme())
+	assert.NoError(t, err)
+
+	claims, ok := ClaimsFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, userID, claims.Subject)
+
+	// 当前用户 ID 默认使用 jti
+	id, err := auther.GetCurrentUserID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.ID, id)
+	assert.Equal(t, []string{"editor", "viewer"}, RolesFromContext(ctx))
+	assert.Equal(t, []string{"api:*", "user:read"}, ScopesFromContext(ctx))
+	assert.True(t, HasScope(ctx, "api:write", "user:read"))
+	assert.False(t, HasScope(ctx, "user:write"))
+	assert.True(t, HasRole(ctx, "admin", "viewer"))
+	assert.False(t, HasRole(ctx, "admin"))
+
+	auther.UserIDClaim = UserIDClaimSub
+	ctx, err = auther.JWTAuth(context.Background(), token, newScheme())
+	assert.NoError(t, err)
+	id, err = CurrentUserID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, id)
+
+	// 零值 JwtAuth 和之前的版本一样使用 jti
+	ctx, err = (&JwtAuth{Validator: auth.Validator()}).JWTAuth(context.Background(), token, newScheme())
+	assert.NoError(t, err)
+	id, err = CurrentUserID(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, claims.ID, id)
+}
+
 func setupJwt() {
 	jwt.C.RSAPrivateKey = rsaKeyPair1[0]
 	jwt.C.RSAPublicKey = rsaKeyPair1[1]
@@ -127,24 +258,31 @@ func newScheme() *security.JWTScheme {
 }
 
 func Test_ValidateScopes(t *testing.T) {
-	err1 := validateScopes([]string{"user:read"}, []string{"user:*"})
+	model := NewScopeModel()
+
+	err1 := model.CheckAll([]string{"user:read"}, []string{"user:*"})
 	assert.NoError(t, err1)
 
-	err2 := validateScopes([]string{"user:read", "user:write"}, []string{"user:read"})
+	err2 := model.CheckAll([]string{"user:read", "user:write"}, []string{"user:read"})
 	assert.EqualError(t, err2, "missing scopes: user:write")
 
-	err3 := validateScopes([]string{"user:read", "user:write"}, []string{"*"})
-	assert.NoError(t, err3)
+	// 第一段使用通配符的 scope 默认不授予权限
+	err3 := model.CheckAll([]string{"user:read", "user:write"}, []string{"*"})
+	assert.EqualError(t, err3, "missing scopes: user:read, user:write")
+
+	err4 := model.CheckAll([]string{"user:read"}, []string{"*:read"})
+	assert.EqualError(t, err4, "missing scopes: user:read")
 
-	err4 := validateScopes([]string{"user:read"}, []string{"*:read"})
-	assert.NoError(t, err4)
+	model.AllowRootWildcard = true
+	assert.NoError(t, model.CheckAll([]string{"user:read", "user:write"}, []string{"*"}))
+	assert.NoError(t, model.CheckAll([]string{"user:read"}, []string{"*:read"}))
 
-	err5 := validateScopes([]string{"user:read"}, []string{"user:read"})
+	err5 := model.CheckAll([]string{"user:read"}, []string{"user:read"})
 	assert.NoError(t, err5)
 
-	err6 := validateScopes([]string{"user:read"}, []string{"group:read", "orgin:read"})
+	err6 := model.CheckAll([]string{"user:read"}, []string{"group:read", "orgin:read"})
 	assert.EqualError(t, err6, "missing scopes: user:read")
 
-	err7 := validateScopes([]string{"user:read"}, []string{"group:read", "user:read"})
+	err7 := model.CheckAll([]string{"user:read"}, []string{"group:read", "user:read"})
 	assert.NoError(t, err7)
 }
@@ -0,0 +1,214 @@
+package goalibs
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	goahttp "goa.design/goa/v3/http"
+	goa "goa.design/goa/v3/pkg"
+)
+
+// defaultMediaType 没有 Accept 时使用的格式
+const defaultMediaType = "application/json"
+
+// ResponseEncoder returns a HTTP response encoder, RequestDecoder 对应的 encoder.
+// 设置了 goahttp.ContentTypeKey (design 中指定了 ContentType) 时使用对应的格式, 没有注册对应的 encoder 时使用 application/json,
+// 否则按 Accept 的 q 值在 RegisterEncoder 注册的格式中协商, 默认 application/json.
+//
+// 没有可用的格式时直接返回 406, 因为 goa 生成的代码在 Encode 之前已经写入状态码,
+// 之后的 Encode 不会再写入任何内容. 使用 NegotiateContent 中间件可以在 handler 执行之前返回 406.
+func ResponseEncoder(ctx context.Context, w http.ResponseWriter) goahttp.Encoder {
+	if ct, ok := ctx.Value(goahttp.ContentTypeKey).(string); ok && ct != "" {
+		mediaType := ct
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			mediaType = mt
+		}
+		fn, exists := codecs.lookupEncoder(mediaType)
+		if !exists {
+			mediaType = defaultMediaType
+			fn, _ = codecs.lookupEncoder(mediaType)
+		}
+		goahttp.SetContentType(w, mediaType)
+		return fn(w)
+	}
+
+	accept, _ := ctx.Value(goahttp.AcceptTypeKey).(string)
+	mediaType, ok := NegotiateContentType(accept)
+	if !ok {
+		writeNotAcceptable(w, accept)
+		return goahttp.EncodingFunc(func(interface{}) error { return nil })
+	}
+	fn, _ := codecs.lookupEncoder(mediaType)
+	goahttp.SetContentType(w, mediaType)
+	return fn(w)
+}
+
+// NegotiateContent 检查请求的 Accept, 没有可用的格式时直接返回 406, 不执行 handler
+func NegotiateContent(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept := r.Header.Get("Accept")
+		if _, ok := NegotiateContentType(accept); !ok {
+			writeNotAcceptable(w, accept)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// NegotiateContentType 按 Accept 选择 ResponseEncoder 使用的格式, 没有可用的格式时返回 false
+// q 值大的优先, q 相同时 application/xml 比 application/* 和 */* 优先, 然后按 Accept 中的顺序,
+// 通配符按 RegisterEncoder 的注册顺序. application/vnd.api+json 等没有注册的格式按后缀使用 json, xml 的 encoder
+func NegotiateContentType(accept string) (string, bool) {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return defaultMediaType, true
+	}
+
+	candidates := codecs.registeredEncoders()
+	for _, r := range ranges {
+		if r.typ == "*" || r.subtype == "*" {
+			continue
+		}
+		if _, exists := codecs.lookupEncoder(r.mediaType()); exists && !containsString(candidates, r.mediaType()) {
+			candidates = append(candidates, r.mediaType())
+		}
+	}
+
+	var (
+		best      string
+		bestRange *acceptRange
+	)
+	for _, c := range candidates {
+		r := matchAcceptRange(ranges, c)
+		if r == nil || r.q <= 0 {
+			continue
+		}
+		if bestRange == nil || r.preferredTo(bestRange) {
+			best, bestRange = c, r
+		}
+	}
+	return best, bestRange != nil
+}
+
+// acceptRange Accept 中的一个 media range
+type acceptRange struct {
+	typ     string
+	subtype string
+	q       float64
+	index   int
+}
+
+func (r *acceptRange) mediaType() string {
+	return r.typ + "/" + r.subtype
+}
+
+// specificity application/xml 为 2, application/* 为 1, */* 为 0
+func (r *acceptRange) specificity() int {
+	switch {
+	case r.typ == "*":
+		return 0
+	case r.subtype == "*":
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (r *acceptRange) matches(mediaType string) bool {
+	if r.typ == "*" {
+		return true
+	}
+	typ, subtype := splitMediaType(mediaType)
+	return r.typ == typ && (r.subtype == "*" || r.subtype == subtype)
+}
+
+// preferredTo 比较 q, specificity 和在 Accept 中的位置, 都相同时保留先注册的格式
+func (r *acceptRange) preferredTo(o *acceptRange) bool {
+	if r.q != o.q {
+		return r.q > o.q
+	}
+	if r.specificity() != o.specificity() {
+		return r.specificity() > o.specificity()
+	}
+	return r.index < o.index
+}
+
+// matchAcceptRange 返回匹配 mediaType 的最具体的 range, 相同时使用 Accept 中靠前的
+func matchAcceptRange(ranges []*acceptRange, mediaType string) *acceptRange {
+	var matched *acceptRange
+	for _, r := range ranges {
+		if !r.matches(mediaType) {
+			continue
+		}
+		if matched == nil || r.specificity() > matched.specificity() {
+			matched = r
+		}
+	}
+	return matched
+}
+
+// parseAccept 解析 Accept, 忽略格式错误的 range
+func parseAccept(accept string) []*acceptRange {
+	var ranges []*acceptRange
+	for i, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		if mediaType == "*" {
+			mediaType = "*/*"
+		}
+		typ, subtype := splitMediaType(mediaType)
+		if typ == "" || subtype == "" || (typ == "*" && subtype != "*") {
+			continue
+		}
+
+		q := 1.0
+		if v, exists := params["q"]; exists {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, &acceptRange{typ: typ, subtype: subtype, q: q, index: i})
+	}
+	return ranges
+}
+
+func splitMediaType(mediaType string) (string, string) {
+	i := strings.IndexByte(mediaType, '/')
+	if i < 0 {
+		return mediaType, ""
+	}
+	return mediaType[:i], mediaType[i+1:]
+}
+
+// writeNotAcceptable 返回 406 错误
+func writeNotAcceptable(w http.ResponseWriter, accept string) {
+	err := goa.PermanentError("not_acceptable", "none of the media types %q is supported, supported: %s",
+		accept, strings.Join(codecs.registeredEncoders(), ", "))
+	writeErrorResponse(w, http.StatusNotAcceptable, err)
+}
+
+// writeErrorResponse 使用 json 返回 goa 格式的错误
+func writeErrorResponse(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", defaultMediaType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(goahttp.NewErrorResponse(err))
+}
+
+func containsString(arr []string, s string) bool {
+	for _, v := range arr {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
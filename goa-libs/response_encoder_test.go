@@ -0,0 +1,135 @@
+package goalibs
+
+import (
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	goahttp "goa.design/goa/v3/http"
+)
+
+func TestNegotiateContentType(t *testing.T) {
+	cases := []struct {
+		accept    string
+		mediaType string
+		ok        bool
+	}{
+		{"", "application/json", true},
+		{"application/xml", "application/xml", true},
+		{"application/xml; charset=utf-8", "application/xml", true},
+		{"*/*", "application/json", true},
+		{"*", "application/json", true},
+		{"application/*", "application/json", true},
+		{"application/xml, application/json", "application/xml", true},
+		{"application/json;q=0.5, application/xml", "application/xml", true},
+		{"application/gob;q=0.9, */*;q=0.1", "application/gob", true},
+		// 具体的 range 优先于通配符
+		{"*/*, application/json;q=0", "application/xml", true},
+		{"application/*;q=0.5, application/xml;q=0.5", "application/xml", true},
+		{"application/vnd.api+json", "application/vnd.api+json", true},
+		{"text/html", "", false},
+		{"application/json;q=0", "", false},
+		{"application/json;q=x, text/plain", "", false},
+		{"text/html, image/*", "", false},
+	}
+	for _, tc := range cases {
+		mediaType, ok := NegotiateContentType(tc.accept)
+		assert.Equal(t, tc.ok, ok, tc.accept)
+		assert.Equal(t, tc.mediaType, mediaType, tc.accept)
+	}
+}
+
+type testResponse struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func encodeTestResponse(ctx context.Context) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	enc := ResponseEncoder(ctx, w)
+	w.WriteHeader(http.StatusOK)
+	_ = enc.Encode(&testResponse{Name: "alice"})
+	return w
+}
+
+func TestResponseEncoder(t *testing.T) {
+	ctx := context.WithValue(context.Background(), goahttp.AcceptTypeKey, "application/xml;q=0.9, application/json;q=0.8")
+	w := encodeTestResponse(ctx)
+	assert.Equal(t, "application/xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "<testResponse><name>alice</name></testResponse>", w.Body.String())
+
+	ctx = context.WithValue(context.Background(), goahttp.AcceptTypeKey, "application/gob")
+	w = encodeTestResponse(ctx)
+	assert.Equal(t, "application/gob", w.Header().Get("Content-Type"))
+	var res testResponse
+	assert.NoError(t, gob.NewDecoder(w.Body).Decode(&res))
+	assert.Equal(t, "alice", res.Name)
+
+	// design 中指定的 ContentType 优先于 Accept
+	ctx = context.WithValue(ctx, goahttp.ContentTypeKey, "application/json")
+	w = encodeTestResponse(ctx)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"name":"alice"}`, w.Body.String())
+
+	// design 中指定的 ContentType 没有注册 encoder 时使用 json, Content-Type 和 body 一致
+	ctx = context.WithValue(ctx, goahttp.ContentTypeKey, "application/x-unknown; charset=utf-8")
+	w = encodeTestResponse(ctx)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"name":"alice"}`, w.Body.String())
+
+	ctx = context.WithValue(context.Background(), goahttp.AcceptTypeKey, "text/html")
+	w = encodeTestResponse(ctx)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var errRes goahttp.ErrorResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &errRes))
+	assert.Equal(t, "not_acceptable", errRes.Name)
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder("text/csv", func(w io.Writer) goahttp.Encoder {
+		return goahttp.EncodingFunc(func(v interface{}) error {
+			_, err := fmt.Fprintf(w, "name\n%s\n", v.(*testResponse).Name)
+			return err
+		})
+	})
+	defer func() {
+		codecs.mu.Lock()
+		delete(codecs.encoders, "text/csv")
+		codecs.encoderTypes = codecs.encoderTypes[:len(codecs.encoderTypes)-1]
+		codecs.mu.Unlock()
+	}()
+
+	ctx := context.WithValue(context.Background(), goahttp.AcceptTypeKey, "text/csv")
+	w := encodeTestResponse(ctx)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, "name\nalice\n", w.Body.String())
+
+	// 通配符优先使用先注册的 json
+	mediaType, _ := NegotiateContentType("*/*")
+	assert.Equal(t, "application/json", mediaType)
+}
+
+func TestNegotiateContent(t *testing.T) {
+	called := false
+	handler := NegotiateContent(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "image/png")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.False(t, called)
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/xml")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, called)
+}
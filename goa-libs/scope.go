@@ -0,0 +1,361 @@
+package goalibs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	goahttp "goa.design/goa/v3/http"
+	goa "goa.design/goa/v3/pkg"
+)
+
+const (
+	// scopeSep scope 的层级分隔符
+	scopeSep = ":"
+	// scopeSubtree 最后一段为 * 时匹配一段或者多段, 为 ** 时还匹配上一级本身
+	scopeSubtree     = "*"
+	scopeSubtreeSelf = "**"
+	// scopeDenyPrefix 以 ! 开头的 scope 表示拒绝, 优先于所有允许的 scope
+	scopeDenyPrefix = "!"
+)
+
+var (
+	ErrEmptyScope = errors.New("empty scope")
+)
+
+// DefaultScopeModel JwtAuth, APIKeyAuth 和 BasicAuth 没有设置 Scopes 时使用的 ScopeModel
+var DefaultScopeModel = NewScopeModel()
+
+// ScopeModel scope 的匹配规则
+//
+// token 中的 scope 按 ":" 分段使用 path.Match 匹配, 每段支持 *, ?, [a-z], [^a-z] 通配符和 \ 转义,
+// 通配符不匹配 "/", 段数必须相同, 例如 user 不包含 user:read.
+// 最后一段为 * 时包含所有下级 scope, 例如 user:* 包含 user:read 和 user:profile:read, 不包含 user;
+// 最后一段为 ** 时同时包含上一级本身, 例如 user:** 包含 user 和 user:read.
+// 其他层级关系需要在 Implies 中声明, 例如 user: [user:*].
+// 第一段使用通配符的 scope (例如 *, *:read) 默认不会授予任何权限, 需要设置 AllowRootWildcard.
+type ScopeModel struct {
+	// Implies token 中的 scope 隐含的 scope, 例如 admin: [user:*, "!user:delete"], 可以传递
+	Implies map[string][]string
+	// RequireAny 为 true 时只需要满足 RequiredScopes 中的任意一个, 默认需要全部满足
+	RequireAny bool
+	// AllowRootWildcard 允许第一段使用通配符的 scope 授予权限
+	AllowRootWildcard bool
+}
+
+// NewScopeModel returns ScopeModel instance.
+func NewScopeModel() *ScopeModel {
+	return &ScopeModel{
+		Implies: map[string][]string{},
+	}
+}
+
+// NewScopeModelWithConf 使用指定配置创建 ScopeModel, 检查 Implies 中 scope 的格式
+// 通配符格式错误时返回的错误可以使用 errors.Is(err, path.ErrBadPattern) 判断
+func NewScopeModelWithConf(c ScopeConf) (*ScopeModel, error) {
+	m := NewScopeModel()
+	for scope, implied := range c.Implies {
+		if err := validateScopePattern(scope); err != nil {
+			return nil, err
+		}
+		for _, s := range implied {
+			if err := validateScopePattern(strings.TrimPrefix(s, scopeDenyPrefix)); err != nil {
+				return nil, err
+			}
+		}
+		m.Implies[scope] = implied
+	}
+	m.RequireAny = c.RequireAny
+	m.AllowRootWildcard = c.AllowRootWildcard
+	return m, nil
+}
+
+// ScopeError 缺少或者被拒绝的 scope, JwtAuth, APIKeyAuth 和 BasicAuth 返回 goa 的 forbidden 错误
+type ScopeError struct {
+	Required []string
+	// Missing 没有被授予的 scope
+	Missing []string
+	// Denied 被 ! 规则拒绝的 scope
+	Denied []string
+	// Any 为 true 表示只需要满足 Required 中的任意一个
+	Any bool
+}
+
+func (e *ScopeError) Error() string {
+	if e.Any {
+		return fmt.Sprintf("missing any of scopes: %s", strings.Join(e.Required, ", "))
+	}
+
+	var parts []string
+	if len(e.Missing) > 0 {
+		parts = append(parts, fmt.Sprintf("missing scopes: %s", strings.Join(e.Missing, ", ")))
+	}
+	if len(e.Denied) > 0 {
+		parts = append(parts, fmt.Sprintf("denied scopes: %s", strings.Join(e.Denied, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ForbiddenErr goa 的 forbidden 错误
+// design 中需要声明 Error("forbidden") 和 Response("forbidden", StatusForbidden),
+// 否则 goa 按默认规则返回 400, 也可以在创建 server 时使用 ErrorFormatter
+func ForbiddenErr(format string, args ...interface{}) error {
+	return goa.PermanentError("forbidden", format, args...)
+}
+
+// scopeForbiddenError JwtAuth, APIKeyAuth 和 BasicAuth 缺少 scope 时返回的错误
+// errors.As 可以取得 *ScopeError 和 forbidden 的 *goa.ServiceError
+// 没有实现 ErrorName, goa 生成的 error encoder 会交给 formatter 处理, 需要使用 ErrorFormatter 返回 403
+type scopeForbiddenError struct {
+	serviceErr *goa.ServiceError
+	scopeErr   *ScopeError
+}
+
+func (e *scopeForbiddenError) Error() string {
+	return e.serviceErr.Error()
+}
+
+func (e *scopeForbiddenError) Unwrap() error {
+	return e.scopeErr
+}
+
+// As 让 errors.As 可以取得 *goa.ServiceError
+func (e *scopeForbiddenError) As(target interface{}) bool {
+	if t, ok := target.(**goa.ServiceError); ok {
+		*t = e.serviceErr
+		return true
+	}
+	return false
+}
+
+// ErrorFormatter goa server 的 error formatter, design 中没有声明的 forbidden 和 unauthorized 错误分别返回 403 和 401
+// 包装了 *goa.ServiceError 的错误 (例如缺少 scope 时的错误) 按其中的 *goa.ServiceError 处理, 其他错误和 goahttp.NewErrorResponse 相同
+//
+//	server := svcsvr.New(endpoints, mux, dec, enc, eh, goalibs.ErrorFormatter)
+func ErrorFormatter(err error) goahttp.Statuser {
+	var serviceErr *goa.ServiceError
+	if errors.As(err, &serviceErr) {
+		err = serviceErr
+	}
+	resp := goahttp.NewErrorResponse(err)
+	errResp, ok := resp.(*goahttp.ErrorResponse)
+	if !ok {
+		return resp
+	}
+	switch errResp.Name {
+	case "forbidden":
+		return &statusErrorResponse{ErrorResponse: errResp, status: http.StatusForbidden}
+	case "unauthorized":
+		return &statusErrorResponse{ErrorResponse: errResp, status: http.StatusUnauthorized}
+	default:
+		return resp
+	}
+}
+
+// statusErrorResponse 指定 status 的 goahttp.ErrorResponse, body 的格式不变
+type statusErrorResponse struct {
+	*goahttp.ErrorResponse
+	status int
+}
+
+// StatusCode returns the HTTP status code.
+func (r *statusErrorResponse) StatusCode() int {
+	return r.status
+}
+
+// Check 按 RequireAny 检查 granted 是否满足 required, 不满足时返回 *ScopeError
+func (m *ScopeModel) Check(required, granted []string) error {
+	if m.RequireAny {
+		return m.CheckAny(required, granted)
+	}
+	return m.CheckAll(required, granted)
+}
+
+// CheckAll 检查 granted 是否包含全部 required
+func (m *ScopeModel) CheckAll(required, granted []string) error {
+	allow, deny := m.expand(granted)
+
+	var missing, denied []string
+	for _, r := range required {
+		switch m.decide(r, allow, deny) {
+		case scopeMissing:
+			missing = append(missing, r)
+		case scopeDenied:
+			denied = append(denied, r)
+		}
+	}
+	if len(missing) == 0 && len(denied) == 0 {
+		return nil
+	}
+	return &ScopeError{Required: required, Missing: missing, Denied: denied}
+}
+
+// CheckAny 检查 granted 是否包含 required 中的任意一个, required 为空时通过
+func (m *ScopeModel) CheckAny(required, granted []string) error {
+	if len(required) == 0 {
+		return nil
+	}
+	allow, deny := m.expand(granted)
+
+	var missing, denied []string
+	for _, r := range required {
+		switch m.decide(r, allow, deny) {
+		case scopeGranted:
+			return nil
+		case scopeMissing:
+			missing = append(missing, r)
+		case scopeDenied:
+			denied = append(denied, r)
+		}
+	}
+	return &ScopeError{Required: required, Missing: missing, Denied: denied, Any: true}
+}
+
+// Expand 返回 granted 加上 Implies 隐含的全部 scope, 拒绝的 scope 带有 ! 前缀
+func (m *ScopeModel) Expand(granted []string) []string {
+	allow, deny := m.expand(granted)
+	scopes := allow
+	for _, d := range deny {
+		scopes = append(scopes, scopeDenyPrefix+d)
+	}
+	return scopes
+}
+
+type scopeDecision int
+
+const (
+	scopeGranted scopeDecision = iota
+	scopeMissing
+	scopeDenied
+)
+
+func (m *ScopeModel) decide(required string, allow, deny []string) scopeDecision {
+	for _, d := range deny {
+		if matchScope(d, required) {
+			return scopeDenied
+		}
+	}
+	for _, a := range allow {
+		if !m.AllowRootWildcard && isRootWildcard(a) {
+			continue
+		}
+		if matchScope(a, required) {
+			return scopeGranted
+		}
+	}
+	return scopeMissing
+}
+
+// expand 展开 Implies, 分别返回允许和拒绝的 scope, 拒绝的 scope 去掉 ! 前缀
+func (m *ScopeModel) expand(granted []string) ([]string, []string) {
+	var allow, deny []string
+	seen := map[string]bool{}
+	queue := append([]string(nil), granted...)
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if s == "" || seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		if strings.HasPrefix(s, scopeDenyPrefix) {
+			deny = append(deny, strings.TrimPrefix(s, scopeDenyPrefix))
+			continue
+		}
+		allow = append(allow, s)
+		queue = append(queue, m.Implies[s]...)
+	}
+	return allow, deny
+}
+
+// checkScopes 检查 scheme 的 RequiredScopes, 不满足时返回包装了 *ScopeError 的 goa forbidden 错误
+func checkScopes(model *ScopeModel, required, granted []string) error {
+	if model == nil {
+		model = DefaultScopeModel
+	}
+	err := model.Check(required, granted)
+	if err == nil {
+		return nil
+	}
+	var scopeErr *ScopeError
+	if !errors.As(err, &scopeErr) {
+		return err
+	}
+	serviceErr := goa.PermanentError("forbidden", "%s", scopeErr.Error())
+	return &scopeForbiddenError{serviceErr: serviceErr, scopeErr: scopeErr}
+}
+
+// scopeModelFromContext 返回认证时使用的 ScopeModel
+func scopeModelFromContext(ctx context.Context) *ScopeModel {
+	if model, ok := ctx.Value(ScopeModelKey).(*ScopeModel); ok && model != nil {
+		return model
+	}
+	return DefaultScopeModel
+}
+
+// matchScope 检查 pattern 是否包含 scope, 段数必须相同, 除非 pattern 的最后一段为 * 或者 **
+// token 中格式错误的 pattern 不匹配任何 scope
+func matchScope(pattern, scope string) bool {
+	patterns := strings.Split(pattern, scopeSep)
+	segments := strings.Split(scope, scopeSep)
+
+	last := len(patterns) - 1
+	switch patterns[last] {
+	case scopeSubtreeSelf:
+		if len(segments) < last {
+			return false
+		}
+		patterns[last] = scopeSubtree
+		if len(segments) == last {
+			patterns = patterns[:last]
+		}
+	case scopeSubtree:
+		if len(segments) < len(patterns) {
+			return false
+		}
+	default:
+		if len(segments) != len(patterns) {
+			return false
+		}
+	}
+
+	for i, s := range segments {
+		p := patterns[len(patterns)-1]
+		if i < len(patterns) {
+			p = patterns[i]
+		}
+		if p == scopeSubtreeSelf {
+			return false
+		}
+		if matched, err := path.Match(p, s); err != nil || !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func isRootWildcard(pattern string) bool {
+	root := strings.SplitN(pattern, scopeSep, 2)[0]
+	return strings.ContainsAny(root, "*?[")
+}
+
+// validateScopePattern 检查 scope 不为空并且每一段都是合法的 path.Match 格式, ** 只能是最后一段
+func validateScopePattern(pattern string) error {
+	if pattern == "" {
+		return ErrEmptyScope
+	}
+	patterns := strings.Split(pattern, scopeSep)
+	for i, p := range patterns {
+		if p == scopeSubtreeSelf && i != len(patterns)-1 {
+			return fmt.Errorf("invalid scope %s: %w", pattern, path.ErrBadPattern)
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid scope %s: %w", pattern, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package goalibs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	goahttp "goa.design/goa/v3/http"
+	goa "goa.design/goa/v3/pkg"
+	"goa.design/goa/v3/security"
+)
+
+func TestMatchScope(t *testing.T) {
+	cases := []struct {
+		pattern string
+		scope   string
+		matched bool
+	}{
+		{"user:read", "user:read", true},
+		{"user:read", "user:write", false},
+		// 段数必须相同, 上级 scope 不包含下级 scope
+		{"user", "user:read", false},
+		{"user", "user:delete", false},
+		{"user:read", "user", false},
+		{"user:read", "user:read:all", false},
+		{"user:*:read", "user:profile:read", true},
+		{"user:*:read", "user:profile:write", false},
+		{"user:*:read", "user:read", false},
+		// 最后一段为 * 时包含所有下级 scope, 为 ** 时还包含上一级本身
+		{"user:*", "user:read", true},
+		{"user:*", "user:profile:read", true},
+		{"user:*", "user", false},
+		{"user:**", "user", true},
+		{"user:**", "user:profile:read", true},
+		{"user:**", "users", false},
+		{"user:re*", "user:read:all", false},
+		{"user:**:read", "user:profile:read", false},
+		// 通配符不会跨越 :
+		{"user*", "users", true},
+		{"user*", "user:read", false},
+		{"us*", "user:read", false},
+		{"user:re*d", "user:read", true},
+		{"user:r?ad", "user:read", true},
+		{"user:r?ad", "user:rad", false},
+		{"repo[0-9]:read", "repo1:read", true},
+		{"repo[0-9]:read", "repoa:read", false},
+		{"repo[^0-9]:read", "repoa:read", true},
+		{"repo[^0-9]:read", "repo1:read", false},
+		{"repo[ab]:read", "repob:read", true},
+		{"repo[a:read", "repo[a:read", false},
+		{`repo\*:read`, "repo*:read", true},
+		{`repo\*:read`, "repox:read", false},
+		{"*a*b", "aXbYb", true},
+		{"*a*b", "aXbYc", false},
+		// 和 path.Match 一样通配符不匹配 /
+		{"files:*", "files:a/b", false},
+		{"files:*/*", "files:a/b", true},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.matched, matchScope(tc.pattern, tc.scope), "%s %s", tc.pattern, tc.scope)
+	}
+}
+
+func TestScopeModel(t *testing.T) {
+	model, err := NewScopeModelWithConf(ScopeConf{
+		Implies: map[string][]string{
+			"admin":  {"user:*", "editor", "!user:delete"},
+			"editor": {"api:read", "api:write", "admin"},
+		},
+	})
+	assert.NoError(t, err)
+
+	// Implies 可以传递, 循环不会死循环
+	assert.Equal(t, []string{"admin", "user:*", "editor", "api:read", "api:write", "!user:delete"}, model.Expand([]string{"admin"}))
+	assert.NoError(t, model.CheckAll([]string{"user:read", "api:write"}, []string{"admin"}))
+	assert.NoError(t, model.CheckAll([]string{"user:read"}, []string{"editor"}))
+
+	err = model.CheckAll([]string{"user:read", "user:delete", "billing:read"}, []string{"admin"})
+	var scopeErr *ScopeError
+	if assert.True(t, errors.As(err, &scopeErr)) {
+		assert.Equal(t, []string{"billing:read"}, scopeErr.Missing)
+		assert.Equal(t, []string{"user:delete"}, scopeErr.Denied)
+	}
+	assert.EqualError(t, err, "missing scopes: billing:read; denied scopes: user:delete")
+
+	// token 中的 ! scope 同样优先
+	err = model.CheckAll([]string{"user:read"}, []string{"user:*", "!user:read"})
+	assert.EqualError(t, err, "denied scopes: user:read")
+
+	// 上级 scope 只有在 Implies 中声明时才包含下级 scope
+	err = model.CheckAll([]string{"user:delete"}, []string{"user"})
+	assert.EqualError(t, err, "missing scopes: user:delete")
+	model.Implies["user"] = []string{"user:*"}
+	assert.NoError(t, model.CheckAll([]string{"user:delete"}, []string{"user"}))
+	delete(model.Implies, "user")
+
+	assert.NoError(t, model.CheckAny([]string{"billing:read", "api:read"}, []string{"editor"}))
+	assert.NoError(t, model.CheckAny(nil, nil))
+	err = model.CheckAny([]string{"billing:read", "user:delete"}, []string{"admin"})
+	assert.EqualError(t, err, "missing any of scopes: billing:read, user:delete")
+
+	model.RequireAny = true
+	assert.NoError(t, model.Check([]string{"billing:read", "api:read"}, []string{"editor"}))
+
+	// 格式错误的通配符在创建时返回错误
+	for _, implies := range []map[string][]string{
+		{"admin": {"repo[0-9:read"}},
+		{"admin": {"!api:[abc"}},
+		{"api:[abc": {"api:read"}},
+		{"admin": {`api:read\`}},
+		{"admin": {"api:**:read"}},
+	} {
+		_, err = NewScopeModelWithConf(ScopeConf{Implies: implies})
+		assert.True(t, errors.Is(err, path.ErrBadPattern), "%v: %v", implies, err)
+	}
+	_, err = NewScopeModelWithConf(ScopeConf{Implies: map[string][]string{"admin": {"!"}}})
+	assert.Equal(t, ErrEmptyScope, err)
+}
+
+func TestScopeModel_Forbidden(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	assert.NoError(t, err)
+	store := NewMemoryCredentialStore()
+	store.PutBasicCredential(BasicCredential{Username: "alice", PasswordHash: hash, Scopes: []string{"admin"}})
+
+	auther := NewBasicAuth(store)
+	auther.Scopes = NewScopeModel()
+	auther.Scopes.Implies["admin"] = []string{"user:*"}
+
+	ctx, err := auther.BasicAuth(context.Background(), "alice", "s3cret", &security.BasicScheme{RequiredScopes: []string{"user:read"}})
+	assert.NoError(t, err)
+	// HasScope 使用认证时的 ScopeModel
+	assert.True(t, HasScope(ctx, "user:write"))
+	assert.True(t, HasAnyScope(ctx, "billing:read", "user:write"))
+	assert.False(t, HasAnyScope(ctx, "billing:read"))
+
+	_, err = auther.BasicAuth(context.Background(), "alice", "s3cret", &security.BasicScheme{RequiredScopes: []string{"billing:read"}})
+	var serviceErr *goa.ServiceError
+	if assert.True(t, errors.As(err, &serviceErr)) {
+		assert.Equal(t, "forbidden", serviceErr.Name)
+		assert.Equal(t, "missing scopes: billing:read", serviceErr.Message)
+	}
+	// 缺少和被拒绝的 scope 可以通过 errors.As 取得
+	var scopeErr *ScopeError
+	if assert.True(t, errors.As(err, &scopeErr)) {
+		assert.Equal(t, []string{"billing:read"}, scopeErr.Missing)
+	}
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, goahttp.ErrorEncoder(goahttp.ResponseEncoder, ErrorFormatter)(context.Background(), rec, err))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"forbidden"`)
+	assert.Contains(t, rec.Body.String(), `"message":"missing scopes: billing:read"`)
+}
+
+func TestErrorFormatter(t *testing.T) {
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{ForbiddenErr("missing scopes: %s", "user:read"), http.StatusForbidden},
+		{goa.TemporaryError("unauthorized", "invalid api key"), http.StatusUnauthorized},
+		{goa.PermanentError("invalid_input", "bad"), http.StatusBadRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		// design 中没有声明的错误由 goahttp.ErrorEncoder 使用 formatter 返回
+		rec := httptest.NewRecorder()
+		encodeError := goahttp.ErrorEncoder(goahttp.ResponseEncoder, ErrorFormatter)
+		assert.NoError(t, encodeError(context.Background(), rec, tc.err))
+		assert.Equal(t, tc.status, rec.Code, "%v", tc.err)
+	}
+
+	rec := httptest.NewRecorder()
+	assert.NoError(t, goahttp.ErrorEncoder(goahttp.ResponseEncoder, ErrorFormatter)(context.Background(), rec, ForbiddenErr("denied")))
+	assert.Contains(t, rec.Body.String(), `"name":"forbidden"`)
+	assert.Contains(t, rec.Body.String(), `"message":"denied"`)
+	assert.NotContains(t, rec.Body.String(), "status")
+}
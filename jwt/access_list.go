@@ -2,6 +2,8 @@ package jwt
 
 import (
 	"strings"
+
+	"github.com/geeksmy/go-libs/util"
 )
 
 // AccessList Errors
@@ -13,9 +15,15 @@ const (
 	ErrNoValues       strError = "no acl.Values"
 
 	ErrUnsupportedACLAction strError = "unsupported access list action: %s"
-	ErrUnsupportedClaim     strError = "access list does not support %s claim, only roles"
+	ErrUnsupportedClaim     strError = "access list does not support %s claim, only roles, scopes, org, sub, aud, iss and meta.<key>"
 )
 
+// aclMetaPrefix meta.<key> 匹配 UserClaims.MetaData 中对应 key 的值
+const aclMetaPrefix = "meta."
+
+// aclClaims access list 支持的 claim
+var aclClaims = []string{"roles", "scopes", "org", "sub", "aud", "iss"}
+
 // AccessListEntry represent an access list entry.
 type AccessListEntry struct {
 	Action string   `json:"action,omitempty"`
@@ -23,6 +31,36 @@ type AccessListEntry struct {
 	Claim  string   `json:"claim,omitempty"`
 }
 
+// ParseAccessListEntry 解析 "<action> <claim> <value> [value...]" 格式的 access list entry
+// 例如 "allow org acme-*", "deny roles suspended"
+func ParseAccessListEntry(s string) (*AccessListEntry, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return nil, ErrEmptyACLAction
+	}
+
+	acl := NewAccessListEntry()
+	if err := acl.SetAction(fields[0]); err != nil {
+		return nil, err
+	}
+	if len(fields) < 2 {
+		return nil, ErrEmptyACLClaim
+	}
+	if err := acl.SetClaim(fields[1]); err != nil {
+		return nil, err
+	}
+	for _, v := range fields[2:] {
+		if err := acl.AddValue(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := acl.Validate(); err != nil {
+		return nil, err
+	}
+	return acl, nil
+}
+
 // NewAccessListEntry return an instance of AccessListEntry.
 func NewAccessListEntry() *AccessListEntry {
 	return &AccessListEntry{}
@@ -39,6 +77,9 @@ func (acl *AccessListEntry) Validate() error {
 	if acl.Claim == "" {
 		return ErrEmptyACLClaim
 	}
+	if !isSupportedACLClaim(acl.Claim) {
+		return ErrUnsupportedClaim.WithArgs(acl.Claim)
+	}
 	if len(acl.Values) == 0 {
 		return ErrNoValues
 	}
@@ -72,7 +113,7 @@ func (acl *AccessListEntry) SetClaim(s string) error {
 	if s == "" {
 		return ErrEmptyClaim
 	}
-	if s != "roles" {
+	if !isSupportedACLClaim(s) {
 		return ErrUnsupportedClaim.WithArgs(s)
 	}
 	acl.Claim = s
@@ -113,33 +154,108 @@ func (acl *AccessListEntry) GetValues() string {
 }
 
 // IsClaimAllowed checks whether access list entry allows the claims.
+// Values 支持 util.Glob 的通配符, 例如 acme-*
+// 第二个返回值为 true 表示 deny 规则匹配, 需要终止后续规则的检查
 func (acl *AccessListEntry) IsClaimAllowed(claims *UserClaims) (bool, bool) {
-	claimMatches := false
+	if _, _, matched := acl.match(claims); !matched {
+		return false, false
+	}
+	if acl.Action == "deny" {
+		return false, true
+	}
+	return acl.Action == "allow", false
+}
+
+// match 返回第一个匹配的 claim 值和 Values 中匹配的 pattern
+func (acl *AccessListEntry) match(claims *UserClaims) (string, string, bool) {
+	for _, claimValue := range acl.claimValues(claims) {
+		for _, value := range acl.Values {
+			if util.Glob(value, claimValue) {
+				return claimValue, value, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// claimValues 返回 claims 中 acl.Claim 对应的值, 空值不参与匹配
+func (acl *AccessListEntry) claimValues(claims *UserClaims) []string {
+	var values []string
 	switch acl.Claim {
 	case "roles":
-		if len(claims.Roles) == 0 {
-			return false, false
+		values = claims.Roles
+	case "scopes":
+		values = claims.Scopes
+	case "org":
+		values = claims.Organizations
+	case "sub":
+		values = []string{claims.Subject}
+	case "aud":
+		values = claims.Audience
+	case "iss":
+		values = []string{claims.Issuer}
+	default:
+		if !strings.HasPrefix(acl.Claim, aclMetaPrefix) {
+			return nil
 		}
-		for _, role := range claims.Roles {
-			if claimMatches {
-				break
-			}
-			for _, value := range acl.Values {
-				if value == role || value == "*" {
-					claimMatches = true
-					if acl.Action == "deny" {
-						return false, true
-					}
-					break
-				}
-			}
+		value, exists := claims.MetaData[strings.TrimPrefix(acl.Claim, aclMetaPrefix)]
+		if !exists {
+			return nil
+		}
+		values = []string{value}
+	}
+
+	nonEmpty := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			nonEmpty = append(nonEmpty, v)
 		}
-	default:
-		return false, false
 	}
+	return nonEmpty
+}
+
+func isSupportedACLClaim(s string) bool {
+	if strings.HasPrefix(s, aclMetaPrefix) {
+		return len(s) > len(aclMetaPrefix)
+	}
+	return containsString(aclClaims, s)
+}
+
+// AccessList 按顺序检查的 access list
+// deny 规则匹配时立即拒绝, 否则只要有 allow 规则匹配即允许, 没有规则匹配时拒绝
+type AccessList []*AccessListEntry
 
-	if claimMatches && acl.Action == "allow" {
-		return true, false
+// ACLDecision access list 的检查结果
+type ACLDecision struct {
+	Allowed bool
+	// Index 决定结果的 entry 在 access list 中的下标, 没有 entry 匹配时为 -1
+	Index int
+	// Entry 决定结果的 entry, 没有 entry 匹配时为 nil
+	Entry *AccessListEntry
+	// Value 匹配的 claim 值
+	Value string
+	// Pattern entry.Values 中匹配的值
+	Pattern string
+}
+
+// Evaluate 检查 claims 是否被 access list 允许, 并返回决定结果的 entry 和匹配的 claim 值
+func (l AccessList) Evaluate(claims *UserClaims) ACLDecision {
+	decision := ACLDecision{Index: -1}
+	for i, entry := range l {
+		value, pattern, matched := entry.match(claims)
+		if !matched {
+			continue
+		}
+
+		switch entry.Action {
+		case "deny":
+			return ACLDecision{Index: i, Entry: entry, Value: value, Pattern: pattern}
+		case "allow":
+			// 之后的 deny 规则仍然可以拒绝, 第一个匹配的 allow 规则决定允许
+			if !decision.Allowed {
+				decision = ACLDecision{Allowed: true, Index: i, Entry: entry, Value: value, Pattern: pattern}
+			}
+		}
 	}
-	return false, false
+	return decision
 }
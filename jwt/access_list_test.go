@@ -1,10 +1,18 @@
 package jwt
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 type AccessListTestInput struct {
@@ -71,13 +79,40 @@ func TestAccessListEntry(t *testing.T) {
 			err:        ErrEmptyClaim,
 		},
 		{
-			name:       "unsupported org claim",
+			name:       "allow org claim",
 			action:     "allow",
 			claim:      "org",
 			values:     []string{"contoso"},
 			shouldFail: false,
+			shouldErr:  false,
+			err:        nil,
+		},
+		{
+			name:       "allow meta claim",
+			action:     "allow",
+			claim:      "meta.tenant",
+			values:     []string{"acme-*"},
+			shouldFail: false,
+			shouldErr:  false,
+			err:        nil,
+		},
+		{
+			name:       "unsupported email claim",
+			action:     "allow",
+			claim:      "email",
+			values:     []string{"jsmith@contoso.com"},
+			shouldFail: false,
+			shouldErr:  true,
+			err:        ErrUnsupportedClaim.WithArgs("email"),
+		},
+		{
+			name:       "empty meta key",
+			action:     "allow",
+			claim:      "meta.",
+			values:     []string{"acme"},
+			shouldFail: false,
 			shouldErr:  true,
-			err:        ErrUnsupportedClaim.WithArgs("org"),
+			err:        ErrUnsupportedClaim.WithArgs("meta."),
 		},
 		{
 			name:       "invalid action",
@@ -316,3 +351,222 @@ func TestAccessList(t *testing.T) {
 		t.Fatalf("Failed %d tests", testFailed)
 	}
 }
+
+func TestAccessList_Claims(t *testing.T) {
+	newEntry := func(action, claim string, values ...string) *AccessListEntry {
+		entry := &AccessListEntry{Action: action, Claim: claim, Values: values}
+		if err := entry.Validate(); err != nil {
+			t.Fatalf("invalid entry %v: %s", entry, err)
+		}
+		return entry
+	}
+
+	// allow org acme-* but deny role suspended
+	accessList := []*AccessListEntry{
+		newEntry("deny", "roles", "suspended"),
+		newEntry("allow", "org", "acme-*"),
+		newEntry("allow", "scopes", "read:*"),
+		newEntry("allow", "sub", "*@contoso.com"),
+		newEntry("allow", "aud", "api"),
+		newEntry("allow", "iss", "https://auth.*"),
+		newEntry("allow", "meta.tenant", "t-0", "t-1*"),
+	}
+
+	cases := []struct {
+		caseName string
+		claims   UserClaims
+		allow    bool
+	}{
+		{"org", UserClaims{Organizations: []string{"contoso", "acme-eu"}}, true},
+		{"org mismatch", UserClaims{Organizations: []string{"acme"}}, false},
+		{"org but suspended", UserClaims{Organizations: []string{"acme-eu"}, Roles: []string{"suspended"}}, false},
+		{"scopes", UserClaims{Scopes: []string{"write:users", "read:users"}}, true},
+		{"sub", UserClaims{Subject: "jsmith@contoso.com"}, true},
+		{"sub mismatch", UserClaims{Subject: "jsmith@example.com"}, false},
+		{"aud", UserClaims{Audience: ClaimStrings{"api"}}, true},
+		{"iss", UserClaims{Issuer: "https://auth.example.com"}, true},
+		{"meta", UserClaims{MetaData: map[string]string{"tenant": "t-12"}}, true},
+		{"meta mismatch", UserClaims{MetaData: map[string]string{"tenant": "x-12"}}, false},
+		{"meta other key", UserClaims{MetaData: map[string]string{"team": "t-12"}}, false},
+		{"empty", UserClaims{}, false},
+	}
+
+	for _, tc := range cases {
+		allowed := false
+		for _, entry := range accessList {
+			claimAllowed, abortProcessing := entry.IsClaimAllowed(&tc.claims)
+			if abortProcessing {
+				allowed = claimAllowed
+				break
+			}
+			if claimAllowed {
+				allowed = true
+			}
+		}
+		if allowed != tc.allow {
+			t.Errorf("FAIL: case %s: expected allowed %t, received %t", tc.caseName, tc.allow, allowed)
+		}
+	}
+}
+
+func TestConf_AccessList(t *testing.T) {
+	secret := "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(strings.NewReader(`
+JWT:
+  AccessList:
+    - deny roles suspended
+    - allow org acme-*
+  StrictRoles: true
+`))
+	assert.NoError(t, err)
+	conf := Conf{}
+	assert.NoError(t, v.UnmarshalKey("JWT", &conf))
+	assert.Equal(t, []string{"deny roles suspended", "allow org acme-*"}, conf.AccessList)
+	assert.True(t, conf.StrictRoles)
+
+	conf.Secret = secret
+	signer, err := NewSignerImplWithConf(conf)
+	assert.NoError(t, err)
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+
+	cases := []struct {
+		caseName string
+		claims   UserClaims
+		allow    bool
+	}{
+		{"org", UserClaims{Organizations: []string{"acme-eu"}}, true},
+		{"suspended", UserClaims{Organizations: []string{"acme-eu"}, Roles: []string{"suspended"}}, false},
+		// strict 模式下没有 roles 的 token 不会被赋予 guest 角色
+		{"no roles", UserClaims{}, false},
+	}
+	for _, tc := range cases {
+		tc.claims.ExpiresAt = time.Now().Add(time.Minute).Unix()
+		token, err := signer.Sign(tc.claims)
+		assert.NoError(t, err)
+
+		claims, err := validator.(ContextValidator).VerifyContext(context.Background(), token)
+		if tc.allow {
+			assert.NoError(t, err, tc.caseName)
+			if assert.NotNil(t, claims) {
+				assert.Empty(t, claims.Roles, tc.caseName)
+			}
+			continue
+		}
+		assert.True(t, errors.Is(err, ErrAccessDenied), "%s: %v", tc.caseName, err)
+		assert.True(t, errors.Is(err, ErrAccessNotAllowed), "%s: %v", tc.caseName, err)
+	}
+}
+
+func TestConf_AccessList_Pflag(t *testing.T) {
+	flagSet := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	BindPflag(flagSet, "jwt_access_list_test")
+	err := flagSet.Parse([]string{
+		"--jwt_access_list", "allow roles admin editor,allow meta.tenant acme",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t,
+		[]string{"allow roles admin editor", "allow meta.tenant acme"},
+		viper.GetStringSlice("jwt_access_list_test.AccessList"))
+}
+
+func TestConf_AccessList_Invalid(t *testing.T) {
+	secret := "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"
+
+	for _, conf := range []Conf{
+		{Secret: secret, AccessList: []string{"permit roles admin"}},
+		{Secret: secret, AccessList: []string{"allow email a@b.c"}},
+		{Secret: secret, AccessList: []string{"allow roles"}},
+		{Secret: secret, AccessList: []string{""}},
+		{Secret: secret, StrictRoles: true},
+	} {
+		_, err := newJwtOption(conf)
+		// nolint(errorlint): fixme
+		e, ok := err.(ConfErr)
+		if assert.True(t, ok, "%v: %v", conf.AccessList, err) {
+			assert.Equal(t, ConfErrCodeAccessList, e.Code())
+		}
+	}
+}
+
+func TestAccessList_Evaluate(t *testing.T) {
+	accessList := AccessList{
+		{Action: "allow", Claim: "roles", Values: []string{"admin", "editor"}},
+		{Action: "deny", Claim: "roles", Values: []string{"suspended"}},
+		{Action: "allow", Claim: "org", Values: []string{"acme-*"}},
+	}
+
+	cases := []struct {
+		caseName string
+		claims   UserClaims
+		expected ACLDecision
+	}{
+		{
+			"first allow decides",
+			UserClaims{Roles: []string{"editor"}, Organizations: []string{"acme-eu"}},
+			ACLDecision{Allowed: true, Index: 0, Entry: accessList[0], Value: "editor", Pattern: "editor"},
+		},
+		{
+			"glob",
+			UserClaims{Organizations: []string{"contoso", "acme-eu"}},
+			ACLDecision{Allowed: true, Index: 2, Entry: accessList[2], Value: "acme-eu", Pattern: "acme-*"},
+		},
+		{
+			"deny overrides earlier allow",
+			UserClaims{Roles: []string{"admin", "suspended"}},
+			ACLDecision{Allowed: false, Index: 1, Entry: accessList[1], Value: "suspended", Pattern: "suspended"},
+		},
+		{
+			"no match",
+			UserClaims{Roles: []string{"guest"}},
+			ACLDecision{Allowed: false, Index: -1},
+		},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, accessList.Evaluate(&tc.claims), tc.caseName)
+	}
+
+	assert.Equal(t, ACLDecision{Index: -1}, AccessList(nil).Evaluate(&UserClaims{}))
+}
+
+func TestTokenValidator_AccessListDryRun(t *testing.T) {
+	secret := "75f03764-147c-4d87-b2f0-4fda89e331c8"
+	core, logs := observer.New(zap.InfoLevel)
+
+	v := NewTokenValidator()
+	defer v.Close()
+	v.TokenSecret = secret
+	v.AccessList = AccessList{{Action: "deny", Claim: "roles", Values: []string{"guest"}}}
+	v.Logger = zap.New(core)
+	assert.NoError(t, v.ConfigureTokenBackends())
+
+	claims := UserClaims{Subject: "user-1", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	token, err := claims.GetToken(SigningMethodHS512, []byte(secret))
+	assert.NoError(t, err)
+
+	_, valid, err := v.ValidateToken(token)
+	assert.False(t, valid)
+	assert.True(t, errors.Is(err, ErrAccessNotAllowed))
+	assert.Equal(t, 0, logs.Len())
+
+	v.AccessListDryRun = true
+	verified, valid, err := v.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+	assert.NotNil(t, verified)
+
+	entries := logs.AllUntimed()
+	if assert.Len(t, entries, 1) {
+		fields := entries[0].ContextMap()
+		assert.Equal(t, false, fields["allowed"])
+		assert.Equal(t, int64(0), fields["index"])
+		assert.Equal(t, "guest", fields["value"])
+		assert.Equal(t, "deny", fields["action"])
+		assert.Equal(t, "user-1", fields["sub"])
+		assert.Equal(t, ErrAccessNotAllowed.Error(), fields["error"])
+	}
+}
@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+	"goa.design/goa/v3/security"
+)
+
+// Authenticator 一组 jwt 配置对应的签发, 验签, 缓存和访问控制列表
+// 同一个进程中可以创建多个, 例如多租户网关中每个签发方一个 Authenticator
+//
+//	auth, err := jwt.NewAuthenticator(conf)
+//	defer auth.Close()
+//	claims, err := auth.VerifyContext(ctx, token)
+//
+// Init/SetupWithConf 初始化的是包级别的默认 Authenticator
+type Authenticator struct {
+	// option 保存 *jwtOption, KeyWatcher 重新加载密钥后原子替换
+	option atomic.Value
+
+	mu      sync.Mutex
+	watcher *KeyWatcher
+}
+
+// NewAuthenticator returns Authenticator instance.
+// conf.KeyReloadInterval 大于 0 时启动 KeyWatcher, 不再使用时需要调用 Close
+func NewAuthenticator(conf Conf) (*Authenticator, error) {
+	a := &Authenticator{}
+	if err := a.setup(conf); err != nil {
+		return nil, err
+	}
+	// 提前创建 TokenValidator, 验签的配置错误在创建时返回
+	if _, err := a.tokenValidator(); err != nil {
+		_ = a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+// setup 使用 conf 初始化, 停止之前的 KeyWatcher, 初始化失败时之后的签发和验签都返回 ErrJwtNotInitialized
+func (a *Authenticator) setup(conf Conf) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopWatcher()
+
+	option, err := newJwtOption(conf)
+	a.storeOption(option)
+	if err != nil {
+		return err
+	}
+
+	if files := conf.keyFiles(); conf.KeyReloadInterval > 0 && len(files) > 0 {
+		interval := time.Duration(conf.KeyReloadInterval) * time.Second
+		a.watcher = NewKeyWatcher(files, interval, func() error {
+			return a.reload(conf)
+		})
+		a.watcher.Start()
+	}
+
+	return nil
+}
+
+// reload 使用 conf 重新读取密钥并替换 option
+// 沿用之前的 revoker, 避免丢失已经吊销的 jti
+// 替换前先创建 TokenValidator, 新的密钥无法用于验签时保留旧的 option
+func (a *Authenticator) reload(conf Conf) error {
+	option, err := newJwtOption(conf)
+	if err != nil {
+		return err
+	}
+	if prev := a.currentOption(); prev != nil {
+		option.revoker = prev.revoker
+	}
+	if _, err := option.sharedTokenValidator(); err != nil {
+		return err
+	}
+	a.storeOption(option)
+	return nil
+}
+
+func (a *Authenticator) currentOption() *jwtOption {
+	option, _ := a.option.Load().(*jwtOption)
+	return option
+}
+
+// storeOption 替换 option, 已经创建的 Signer/Validator 之后都使用新的 option
+func (a *Authenticator) storeOption(option *jwtOption) {
+	prev := a.currentOption()
+	a.option.Store(option)
+	if prev != nil {
+		prev.close()
+	}
+}
+
+func (a *Authenticator) stopWatcher() {
+	if a.watcher != nil {
+		_ = a.watcher.Close()
+		a.watcher = nil
+	}
+}
+
+func (a *Authenticator) tokenValidator() (*TokenValidator, error) {
+	option := a.currentOption()
+	if option == nil {
+		return nil, ErrJwtNotInitialized
+	}
+	return option.sharedTokenValidator()
+}
+
+// Close 停止 KeyWatcher 和缓存清理, 可以重复调用
+func (a *Authenticator) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.stopWatcher()
+	if option := a.currentOption(); option != nil {
+		option.close()
+	}
+	return nil
+}
+
+// Signer 返回使用 a 签发的 Signer
+func (a *Authenticator) Signer() Issuer {
+	return SignerImpl{auth: a}
+}
+
+// Validator 返回使用 a 验签的 Validator, 所有返回的 Validator 共用一个 TokenValidator
+func (a *Authenticator) Validator() ClaimsValidator {
+	return ValidatorImpl{auth: a}
+}
+
+// Sign 签发 jwt, claim 可以是 UserClaims 或者嵌入 UserClaims 的自定义结构体
+func (a *Authenticator) Sign(claim jwtlib.Claims) (string, error) {
+	return SignerImpl{auth: a}.Sign(claim)
+}
+
+// Issue 签发 jwt 并填充标准声明, 返回 jwt 和过期时间
+func (a *Authenticator) Issue(subject string, roles, scopes []string) (string, time.Time, error) {
+	return SignerImpl{auth: a}.Issue(subject, roles, scopes)
+}
+
+// Verify 验证 jwt, 错误会转换为 goa 的 unauthorized 错误
+//
+// Deprecated: 使用 VerifyContext, 在 goa 服务中使用 goajwt.ErrorContext 转换错误
+func (a *Authenticator) Verify(token string, scheme *security.JWTScheme) (*UserClaims, error) {
+	return ValidatorImpl{auth: a}.Verify(token, scheme)
+}
+
+// VerifyContext 验证 jwt, 返回的错误可以使用 errors.Is 判断错误类型
+func (a *Authenticator) VerifyContext(ctx context.Context, token string) (*UserClaims, error) {
+	return ValidatorImpl{auth: a}.VerifyContext(ctx, token)
+}
+
+// VerifyClaims 验证 jwt, 并把 payload 解码到 claims
+func (a *Authenticator) VerifyClaims(ctx context.Context, token string, claims interface{}) (*UserClaims, error) {
+	return ValidatorImpl{auth: a}.VerifyClaims(ctx, token, claims)
+}
+
+// RevokeToken 吊销 jti, 在 token 过期之前验证都会返回 ErrTokenRevoked
+func (a *Authenticator) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	return ValidatorImpl{auth: a}.RevokeToken(ctx, jti, expiresAt)
+}
+
+// Cache 返回验签使用的 TokenCache, 禁用缓存或者没有初始化时返回 nil
+// 重新加载密钥后会使用新的 TokenCache
+func (a *Authenticator) Cache() *TokenCache {
+	v, err := a.tokenValidator()
+	if err != nil {
+		return nil
+	}
+	return v.Cache
+}
+
+// AccessList 返回验签使用的访问控制列表, 没有初始化时返回 nil
+func (a *Authenticator) AccessList() AccessList {
+	option := a.currentOption()
+	if option == nil {
+		return nil
+	}
+	return option.accessList
+}
+
+// Evaluate 使用访问控制列表检查 claims, 不验证 jwt
+func (a *Authenticator) Evaluate(claims *UserClaims) ACLDecision {
+	return a.AccessList().Evaluate(claims)
+}
+
+// JWKSHandler 返回发布当前公钥的 JWKS handler, 一般挂载在 /.well-known/jwks.json
+// 每次请求都读取当前的 keyring, KeyWatcher 重新加载密钥后发布新的公钥
+func (a *Authenticator) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		option := a.currentOption()
+		if option == nil {
+			http.Error(w, ErrJwtNotInitialized.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		serveJWKS(w, r, option.keyring)
+	})
+}
@@ -0,0 +1,97 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticator_MultipleIssuers(t *testing.T) {
+	ctx := context.Background()
+
+	tenantA, err := NewAuthenticator(Conf{
+		Secret:          "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM",
+		TokenIssuer:     "tenant-a",
+		ExpectedIssuers: []string{"tenant-a"},
+		Revoker:         RevokerMemory,
+	})
+	assert.NoError(t, err)
+	defer tenantA.Close()
+
+	tenantB, err := NewAuthenticator(Conf{
+		RSAPrivateKey:   rsaKeyPair1[0],
+		TokenIssuer:     "tenant-b",
+		ExpectedIssuers: []string{"tenant-b"},
+		Revoker:         RevokerMemory,
+		AccessList:      []string{"allow roles admin"},
+	})
+	assert.NoError(t, err)
+	defer tenantB.Close()
+
+	tokenA, expiresAt, err := tenantA.Issue("alice", []string{"guest"}, nil)
+	assert.NoError(t, err)
+	tokenB, _, err := tenantB.Issue("bob", []string{"admin"}, nil)
+	assert.NoError(t, err)
+
+	claims, err := tenantA.VerifyContext(ctx, tokenA)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", claims.Subject)
+
+	claims, err = tenantB.Validator().VerifyContext(ctx, tokenB)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", claims.Subject)
+
+	_, err = tenantA.VerifyContext(ctx, tokenB)
+	assert.Error(t, err)
+	_, err = tenantB.VerifyContext(ctx, tokenA)
+	assert.Error(t, err)
+
+	// 吊销只影响各自的 Authenticator
+	assert.NoError(t, tenantA.RevokeToken(ctx, claims.ID, expiresAt))
+	_, err = tenantB.VerifyContext(ctx, tokenB)
+	assert.NoError(t, err)
+
+	assert.NotNil(t, tenantA.Cache())
+	assert.NotSame(t, tenantA.Cache(), tenantB.Cache())
+	assert.Len(t, tenantB.AccessList(), 1)
+	assert.True(t, tenantB.Evaluate(claims).Allowed)
+	assert.False(t, tenantA.Evaluate(claims).Allowed)
+}
+
+func TestAuthenticator_NotInitialized(t *testing.T) {
+	ctx := context.Background()
+	auth := &Authenticator{}
+
+	_, err := auth.VerifyContext(ctx, "token")
+	assert.True(t, errors.Is(err, ErrJwtNotInitialized), "%v", err)
+	_, err = auth.Sign(UserClaims{})
+	assert.True(t, errors.Is(err, ErrJwtNotInitialized), "%v", err)
+	_, _, err = auth.Issue("alice", nil, nil)
+	assert.True(t, errors.Is(err, ErrJwtNotInitialized), "%v", err)
+	assert.True(t, errors.Is(auth.RevokeToken(ctx, "jti", time.Now()), ErrJwtNotInitialized))
+	assert.Nil(t, auth.Cache())
+	assert.Nil(t, auth.AccessList())
+	assert.NoError(t, auth.Close())
+
+	_, err = ValidatorImpl{}.VerifyContext(ctx, "token")
+	assert.True(t, errors.Is(err, ErrJwtNotInitialized), "%v", err)
+	_, err = SignerImpl{}.Sign(UserClaims{})
+	assert.True(t, errors.Is(err, ErrJwtNotInitialized), "%v", err)
+}
+
+func TestNewAuthenticator_InvalidConf(t *testing.T) {
+	_, err := NewAuthenticator(Conf{})
+	assert.Equal(t, ErrNoRequiredSecret, err)
+
+	// 验签的配置错误在创建时返回
+	_, err = NewAuthenticator(Conf{Secret: "short"})
+	assert.Error(t, err)
+
+	auth, err := NewAuthenticator(Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"})
+	assert.NoError(t, err)
+	assert.NoError(t, auth.Close())
+	assert.NoError(t, auth.Close())
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 
 	jwtlib "github.com/dgrijalva/jwt-go"
@@ -11,6 +13,8 @@ const (
 	ErrInvalidSecretLength strError = "secrets less than 16 characters in length are not allowed"
 	ErrUnexpectedKID       strError = "the kid specified in the header was not found"
 	ErrNoRSAKeyFound       strError = "no RSA key found"
+	ErrNoECKeyFound        strError = "no ECDSA key found"
+	ErrNoEdKeyFound        strError = "no Ed25519 key found"
 
 	ErrUnexpectedSigningMethod strError = "signing method mismatch: %v (expected) vs. %v (received)"
 )
@@ -73,3 +77,63 @@ func (b *RSAKeyTokenBackend) ProvideKey(token *jwtlib.Token) (interface{}, error
 
 	return nil, ErrNoRSAKeyFound
 }
+
+// ECDSAKeyTokenBackend hold asymentric keys from ES family.
+type ECDSAKeyTokenBackend struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  *ecdsa.PublicKey
+}
+
+// NewECDSAKeyTokenBackend returns ECDSAKeyTokenBackend instance.
+func NewECDSAKeyTokenBackend(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) *ECDSAKeyTokenBackend {
+	b := &ECDSAKeyTokenBackend{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+	}
+	return b
+}
+
+// ProvideKey provides key material from ECDSAKeyTokenBackend.
+func (b *ECDSAKeyTokenBackend) ProvideKey(token *jwtlib.Token) (interface{}, error) {
+	if _, validMethod := token.Method.(*jwtlib.SigningMethodECDSA); !validMethod {
+		return nil, ErrUnexpectedSigningMethod.WithArgs("ES", token.Header["alg"])
+	}
+
+	if b.privateKey != nil {
+		return &b.privateKey.PublicKey, nil
+	} else if b.publicKey != nil {
+		return b.publicKey, nil
+	}
+
+	return nil, ErrNoECKeyFound
+}
+
+// Ed25519KeyTokenBackend hold asymentric keys for EdDSA.
+type Ed25519KeyTokenBackend struct {
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// NewEd25519KeyTokenBackend returns Ed25519KeyTokenBackend instance.
+func NewEd25519KeyTokenBackend(privateKey ed25519.PrivateKey, publicKey ed25519.PublicKey) *Ed25519KeyTokenBackend {
+	b := &Ed25519KeyTokenBackend{
+		privateKey: privateKey,
+		publicKey:  publicKey,
+	}
+	return b
+}
+
+// ProvideKey provides key material from Ed25519KeyTokenBackend.
+func (b *Ed25519KeyTokenBackend) ProvideKey(token *jwtlib.Token) (interface{}, error) {
+	if _, validMethod := token.Method.(*signingMethodEdDSA); !validMethod {
+		return nil, ErrUnexpectedSigningMethod.WithArgs("EdDSA", token.Header["alg"])
+	}
+
+	if b.privateKey != nil {
+		return b.privateKey.Public(), nil
+	} else if b.publicKey != nil {
+		return b.publicKey, nil
+	}
+
+	return nil, ErrNoEdKeyFound
+}
@@ -1,76 +1,275 @@
 package jwt
 
 import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// 默认最多缓存的 token 数量
+	defaultTokenCacheMaxEntries = 10000
+	// 默认 token 在缓存中最长保留的时间, token 的 exp 更早时以 exp 为准
+	defaultTokenCacheTTL = time.Minute * 5
+	// 清理过期 token 的间隔
+	tokenCacheSweepInterval = time.Minute
+)
+
+var (
+	tokenCacheHitsDesc = prometheus.NewDesc(
+		"jwt_token_cache_hits_total", "Number of token cache hits.", nil, nil)
+	tokenCacheMissesDesc = prometheus.NewDesc(
+		"jwt_token_cache_misses_total", "Number of token cache misses.", nil, nil)
+	tokenCacheEvictionsDesc = prometheus.NewDesc(
+		"jwt_token_cache_evictions_total", "Number of tokens evicted because the cache is full.", nil, nil)
+	tokenCacheEntriesDesc = prometheus.NewDesc(
+		"jwt_token_cache_entries", "Number of tokens currently cached.", nil, nil)
 )
 
-// TokenCache contains cached tokens
+// TokenCache contains cached tokens.
+// 缓存使用 token 的 sha256 摘要作为 key, 不保存 token 明文
+// 超过 MaxEntries 时淘汰最近最少使用的 token, 每个 token 最多保留 TTL, 并且不会超过它的 exp
+// TokenCache 实现了 prometheus.Collector, 可以直接注册到 prometheus
 type TokenCache struct {
-	mu      sync.RWMutex
-	Entries map[string]UserClaims
+	// MaxEntries 最多缓存的 token 数量, 小于等于 0 时不限制
+	MaxEntries int
+	// TTL token 在缓存中最长保留的时间, 小于等于 0 时只以 exp 为准
+	TTL time.Duration
+
+	mu      sync.Mutex
+	ll      *list.List
+	entries map[string]*list.Element
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type tokenCacheEntry struct {
+	key       string
+	claims    UserClaims
+	expiresAt time.Time
+}
+
+// TokenCacheStats 缓存的统计数据
+type TokenCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
 }
 
 // NewTokenCache returns TokenCache instance.
+// 会启动一个定期清理过期 token 的 goroutine, 不再使用时需要调用 Close
 func NewTokenCache() *TokenCache {
 	c := &TokenCache{
-		Entries: map[string]UserClaims{},
+		MaxEntries: defaultTokenCacheMaxEntries,
+		TTL:        defaultTokenCacheTTL,
+		ll:         list.New(),
+		entries:    map[string]*list.Element{},
+		done:       make(chan struct{}),
 	}
-	go manageTokenCache(c)
+	go c.sweep(tokenCacheSweepInterval)
 	return c
 }
 
-func manageTokenCache(cache *TokenCache) {
-	intervals := time.NewTicker(time.Minute * time.Duration(5))
-	for range intervals.C {
-		if cache == nil {
+func (c *TokenCache) sweep(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
 			return
+		case now := <-ticker.C:
+			c.removeExpired(now)
 		}
-		cache.mu.RLock()
-		if cache.Entries == nil {
-			cache.mu.RUnlock()
-			continue
-		}
-		cache.mu.RUnlock()
-		cache.mu.Lock()
-		for k, claims := range cache.Entries {
-			if err := claims.Valid(); err != nil {
-				delete(cache.Entries, k)
-			}
-		}
-		cache.mu.Unlock()
 	}
 }
 
+// Close 停止清理 goroutine, 可以重复调用
+func (c *TokenCache) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+	return nil
+}
+
 // Add adds a token and the associated claim to cache.
 func (c *TokenCache) Add(token string, claims UserClaims) error {
+	now := time.Now()
+	expiresAt := time.Time{}
+	if c.TTL > 0 {
+		expiresAt = now.Add(c.TTL)
+	}
+	if claims.ExpiresAt > 0 {
+		exp := time.Unix(claims.ExpiresAt, 0)
+		if expiresAt.IsZero() || exp.Before(expiresAt) {
+			expiresAt = exp
+		}
+	}
+
+	key := tokenCacheKey(token)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.Entries[token] = claims
+
+	if elem, exists := c.entries[key]; exists {
+		entry := elem.Value.(*tokenCacheEntry)
+		entry.claims = claims
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(elem)
+		return nil
+	}
+
+	entry := &tokenCacheEntry{key: key, claims: claims, expiresAt: expiresAt}
+	c.entries[key] = c.ll.PushFront(entry)
+
+	for c.MaxEntries > 0 && c.ll.Len() > c.MaxEntries {
+		c.removeElement(c.ll.Back())
+		atomic.AddUint64(&c.evictions, 1)
+	}
 	return nil
 }
 
 // Delete removes cached token from
 func (c *TokenCache) Delete(token string) error {
+	key := tokenCacheKey(token)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	delete(c.Entries, token)
+	if elem, exists := c.entries[key]; exists {
+		c.removeElement(elem)
+	}
+	return nil
+}
+
+// DeleteID removes all cached tokens whose jti equals id.
+func (c *TokenCache) DeleteID(id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.ll.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*tokenCacheEntry).claims.ID == id {
+			c.removeElement(elem)
+		}
+		elem = next
+	}
 	return nil
 }
 
 // Get returns user claims if the token associated with
 // the claim exists in cache. If the token is expired, it
 // will be removed from the cache.
+// 和之前的版本一样, 没有 exp 的 token 视为已过期, 不允许时钟偏差
 func (c *TokenCache) Get(token string) *UserClaims {
-	c.mu.RLock()
-	claims, exists := c.Entries[token]
-	c.mu.RUnlock()
+	return c.get(token, time.Now(), 0, true)
+}
+
+// Entries 返回缓存的 claims 的副本, key 为 token 的 sha256 摘要, 不再是 token 明文
+//
+// Deprecated: 缓存不再保存 token 明文, 使用 Get, Len 或者 Stats
+func (c *TokenCache) Entries() map[string]UserClaims {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := make(map[string]UserClaims, len(c.entries))
+	for key, elem := range c.entries {
+		entries[key] = elem.Value.(*tokenCacheEntry).claims
+	}
+	return entries
+}
+
+// get 和 Get 相同, 使用 TokenValidator 的 leeway 和 requireExp 检查 exp, nbf, iat
+func (c *TokenCache) get(token string, now time.Time, leeway time.Duration, requireExp bool) *UserClaims {
+	key := tokenCacheKey(token)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, exists := c.entries[key]
 	if !exists {
+		atomic.AddUint64(&c.misses, 1)
 		return nil
 	}
-	if claims.ExpiresAt < time.Now().Unix() {
-		_ = c.Delete(token)
+
+	entry := elem.Value.(*tokenCacheEntry)
+	if entry.expired(now) || entry.claims.validAt(now, leeway, requireExp) != nil {
+		c.removeElement(elem)
+		atomic.AddUint64(&c.misses, 1)
 		return nil
 	}
+
+	c.ll.MoveToFront(elem)
+	atomic.AddUint64(&c.hits, 1)
+	claims := entry.claims
 	return &claims
 }
+
+// Len 返回缓存的 token 数量, 包括已经过期但还没有被清理的 token
+func (c *TokenCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// Stats 返回缓存的统计数据
+func (c *TokenCache) Stats() TokenCacheStats {
+	return TokenCacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   c.Len(),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *TokenCache) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tokenCacheHitsDesc
+	ch <- tokenCacheMissesDesc
+	ch <- tokenCacheEvictionsDesc
+	ch <- tokenCacheEntriesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *TokenCache) Collect(ch chan<- prometheus.Metric) {
+	stats := c.Stats()
+	ch <- prometheus.MustNewConstMetric(tokenCacheHitsDesc, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(tokenCacheMissesDesc, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(tokenCacheEvictionsDesc, prometheus.CounterValue, float64(stats.Evictions))
+	ch <- prometheus.MustNewConstMetric(tokenCacheEntriesDesc, prometheus.GaugeValue, float64(stats.Entries))
+}
+
+func (c *TokenCache) removeExpired(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.ll.Back(); elem != nil; {
+		prev := elem.Prev()
+		if elem.Value.(*tokenCacheEntry).expired(now) {
+			c.removeElement(elem)
+		}
+		elem = prev
+	}
+}
+
+func (c *TokenCache) removeElement(elem *list.Element) {
+	c.ll.Remove(elem)
+	delete(c.entries, elem.Value.(*tokenCacheEntry).key)
+}
+
+func tokenCacheKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (e *tokenCacheEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
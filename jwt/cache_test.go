@@ -1,8 +1,13 @@
 package jwt
 
 import (
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 )
 
 func newDummyClaims() *UserClaims {
@@ -28,13 +33,14 @@ func TestTokenCache(t *testing.T) {
 	t.Logf("Claims: %v", claims)
 
 	c := NewTokenCache()
-	t.Logf("Token cache contains %d entries", len(c.Entries))
+	defer c.Close()
+	t.Logf("Token cache contains %d entries", c.Len())
 
 	_ = c.Add(token, *claims)
-	if len(c.Entries) != 1 {
-		t.Fatalf("Token cache contains %d entries, not the expected 1 entry", len(c.Entries))
+	if c.Len() != 1 {
+		t.Fatalf("Token cache contains %d entries, not the expected 1 entry", c.Len())
 	}
-	t.Logf("Token cache contains %d entries", len(c.Entries))
+	t.Logf("Token cache contains %d entries", c.Len())
 
 	cachedClaims := c.Get(token)
 	if cachedClaims == nil {
@@ -44,8 +50,8 @@ func TestTokenCache(t *testing.T) {
 	t.Logf("Cached Claims: %v", claims)
 
 	_ = c.Delete(token)
-	if len(c.Entries) != 0 {
-		t.Fatalf("Token cache contains %d entries, not the expected 0 entries", len(c.Entries))
+	if c.Len() != 0 {
+		t.Fatalf("Token cache contains %d entries, not the expected 0 entries", c.Len())
 	}
 
 	claims = newDummyClaims()
@@ -55,17 +61,109 @@ func TestTokenCache(t *testing.T) {
 		t.Fatalf("Failed to get JWT token for %v: %s", claims, err)
 	}
 	_ = c.Add(token, *claims)
-	if len(c.Entries) != 1 {
-		t.Fatalf("Token cache contains %d entries, not the expected 1 entry", len(c.Entries))
+	if c.Len() != 1 {
+		t.Fatalf("Token cache contains %d entries, not the expected 1 entry", c.Len())
 	}
-	t.Logf("Token cache contains %d entries", len(c.Entries))
+	t.Logf("Token cache contains %d entries", c.Len())
 	cachedClaims = c.Get(token)
 	if cachedClaims != nil {
 		t.Fatalf("Token cache returned previously cached expired claims")
 	}
-	if len(c.Entries) != 0 {
-		t.Fatalf("Token cache contains %d entries, not the expected 0 entries", len(c.Entries))
+	if c.Len() != 0 {
+		t.Fatalf("Token cache contains %d entries, not the expected 0 entries", c.Len())
 	}
 
 	t.Logf("Passed")
 }
+
+func TestTokenCache_LRU(t *testing.T) {
+	c := NewTokenCache()
+	defer c.Close()
+	c.MaxEntries = 2
+
+	claims := *newDummyClaims()
+	_ = c.Add("a", claims)
+	_ = c.Add("b", claims)
+	// 访问 a 之后 b 成为最近最少使用的 token
+	assert.NotNil(t, c.Get("a"))
+	_ = c.Add("c", claims)
+
+	assert.Equal(t, 2, c.Len())
+	assert.NotNil(t, c.Get("a"))
+	assert.Nil(t, c.Get("b"))
+	assert.NotNil(t, c.Get("c"))
+
+	stats := c.Stats()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(1), stats.Misses)
+	assert.Equal(t, uint64(1), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestTokenCache_TTL(t *testing.T) {
+	c := NewTokenCache()
+	defer c.Close()
+
+	// exp 早于 TTL 时以 exp 为准
+	claims := *newDummyClaims()
+	claims.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	_ = c.Add("expired", claims)
+	c.removeExpired(time.Now())
+	assert.Equal(t, 0, c.Len())
+
+	c.TTL = time.Millisecond
+	_ = c.Add("short", *newDummyClaims())
+	time.Sleep(time.Millisecond * 2)
+	assert.Nil(t, c.Get("short"))
+	assert.Equal(t, 0, c.Len())
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close())
+}
+
+func TestTokenCache_ValidAt(t *testing.T) {
+	c := NewTokenCache()
+	defer c.Close()
+	now := time.Now()
+
+	// nbf 在 leeway 之内时使用 TokenValidator 的 leeway 可以命中缓存
+	claims := *newDummyClaims()
+	claims.NotBefore = now.Add(30 * time.Second).Unix()
+	_ = c.Add("nbf", claims)
+	assert.NotNil(t, c.get("nbf", now, time.Minute, true))
+	assert.Nil(t, c.get("nbf", now, 0, true))
+	assert.Equal(t, 0, c.Len())
+
+	// 没有 exp 的 token 只有在允许时才命中缓存
+	claims = *newDummyClaims()
+	claims.ExpiresAt = 0
+	_ = c.Add("no-exp", claims)
+	assert.NotNil(t, c.get("no-exp", now, 0, false))
+	assert.Equal(t, map[string]UserClaims{tokenCacheKey("no-exp"): claims}, c.Entries())
+	assert.Nil(t, c.Get("no-exp"))
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestTokenCache_Collector(t *testing.T) {
+	c := NewTokenCache()
+	defer c.Close()
+
+	_ = c.Add("a", *newDummyClaims())
+	c.Get("a")
+	c.Get("b")
+
+	registry := prometheus.NewRegistry()
+	assert.NoError(t, registry.Register(c))
+
+	expected := `
+# HELP jwt_token_cache_hits_total Number of token cache hits.
+# TYPE jwt_token_cache_hits_total counter
+jwt_token_cache_hits_total 1
+# HELP jwt_token_cache_misses_total Number of token cache misses.
+# TYPE jwt_token_cache_misses_total counter
+jwt_token_cache_misses_total 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"jwt_token_cache_hits_total", "jwt_token_cache_misses_total")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,60 @@
+package jwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+)
+
+// Custom Claims Errors
+const (
+	ErrInvalidClaimsTarget strError = "claims target must be a non-nil pointer, got %s"
+	ErrDecodeClaims        strError = "failed to decode claims: %v"
+	ErrNoClaimsValidator   strError = "validator does not support custom claims"
+)
+
+// CustomClaims 自定义 claims, 嵌入 UserClaims 的结构体都实现了这个接口
+//
+//	type TenantClaims struct {
+//		jwt.UserClaims
+//		TenantID    string          `json:"tenant_id"`
+//		Permissions int64           `json:"perm"`
+//		Profile     map[string]int  `json:"profile"`
+//	}
+type CustomClaims interface {
+	jwtlib.Claims
+	GetUserClaims() UserClaims
+}
+
+// GetUserClaims 返回嵌入的 UserClaims, Signer 据此识别嵌入了 UserClaims 的自定义 claims
+func (u UserClaims) GetUserClaims() UserClaims {
+	return u
+}
+
+// decodeClaims 把 token 的 payload 解码到 claims, 调用方需要先验证 token
+func decodeClaims(token string, claims interface{}) error {
+	if claims == nil {
+		return ErrInvalidClaimsTarget.WithArgs(fmt.Sprintf("%T", claims))
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrDecodeClaims.WithArgs("token contains an invalid number of segments")
+	}
+
+	payload, err := jwtlib.DecodeSegment(parts[1])
+	if err != nil {
+		return ErrDecodeClaims.WithArgs(err)
+	}
+
+	if err := json.Unmarshal(payload, claims); err != nil {
+		// nolint(errorlint): json.InvalidUnmarshalError 表示 claims 不是指针
+		if _, ok := err.(*json.InvalidUnmarshalError); ok {
+			return ErrInvalidClaimsTarget.WithArgs(fmt.Sprintf("%T", claims))
+		}
+		return ErrDecodeClaims.WithArgs(err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantClaims struct {
+	UserClaims
+	TenantID    string         `json:"tenant_id"`
+	Permissions int64          `json:"perm"`
+	Profile     tenantProfile  `json:"profile"`
+	Quotas      map[string]int `json:"quotas,omitempty"`
+}
+
+type tenantProfile struct {
+	Plan    string   `json:"plan"`
+	Regions []string `json:"regions"`
+}
+
+func TestCustomClaims(t *testing.T) {
+	ctx := context.Background()
+	conf := Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"}
+	signer, err := NewSignerImplWithConf(conf)
+	assert.NoError(t, err)
+	v, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	validator := v.(ClaimsValidator)
+
+	claims := tenantClaims{
+		UserClaims: UserClaims{
+			Subject:   "user-1",
+			ExpiresAt: time.Now().Add(time.Minute).Unix(),
+			Roles:     []string{"guest"},
+		},
+		TenantID:    "acme",
+		Permissions: 1<<40 | 7,
+		Profile:     tenantProfile{Plan: "pro", Regions: []string{"eu", "us"}},
+		Quotas:      map[string]int{"users": 10},
+	}
+
+	for _, c := range []CustomClaims{claims, &claims} {
+		token, err := signer.Sign(c)
+		assert.NoError(t, err)
+
+		decoded := tenantClaims{}
+		userClaims, err := validator.VerifyClaims(ctx, token, &decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, claims, decoded)
+		if assert.NotNil(t, userClaims) {
+			assert.Equal(t, "user-1", userClaims.Subject)
+		}
+
+		// 第二次验证命中缓存, 同样可以解码
+		decoded = tenantClaims{}
+		_, err = validator.VerifyClaims(ctx, token, &decoded)
+		assert.NoError(t, err)
+		assert.Equal(t, claims.TenantID, decoded.TenantID)
+
+		_, err = validator.VerifyClaims(ctx, token, decoded)
+		assert.True(t, errors.Is(err, ErrInvalidClaimsTarget), "%v", err)
+		_, err = validator.VerifyClaims(ctx, token, nil)
+		assert.True(t, errors.Is(err, ErrInvalidClaimsTarget), "%v", err)
+	}
+
+	// 自定义 claims 同样需要通过标准声明的检查
+	claims.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	token, err := signer.Sign(claims)
+	assert.NoError(t, err)
+	_, err = validator.VerifyClaims(ctx, token, &tenantClaims{})
+	assert.True(t, errors.Is(err, ErrTokenExpired), "%v", err)
+}
@@ -1,8 +1,11 @@
 package jwt
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"io/ioutil"
+	"crypto/x509"
+	"encoding/pem"
 	"os"
 
 	jwtlib "github.com/dgrijalva/jwt-go"
@@ -22,38 +25,121 @@ type CommonTokenConfig struct {
 
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
+	// inline PEM 可能超过文件名长度限制, 除了不存在以外的错误同样视为不是文件
+	if err != nil {
 		return false
 	}
 	return !info.IsDir()
 }
 
+// ParseRSAPrivateKeyFromPEM 解析 RSA 私钥, key 可以是 file://, env:// 或者直接配置的 PEM
 func ParseRSAPrivateKeyFromPEM(key string) (*rsa.PrivateKey, error) {
-	// 检查 key 是不是文件
-	// 如果是则从文件获取 key
-	if fileExists(key) {
-		content, err := ioutil.ReadFile(key)
-		if err != nil {
-			return nil, err
-		}
+	return parseRSAPrivateKey(key, "")
+}
 
-		key = string(content)
+func parseRSAPrivateKey(key, passphrase string) (*rsa.PrivateKey, error) {
+	content, err := loadPrivateKeyPEM(key, passphrase)
+	if err != nil {
+		return nil, err
 	}
 
-	return jwtlib.ParseRSAPrivateKeyFromPEM([]byte(key))
+	return jwtlib.ParseRSAPrivateKeyFromPEM(content)
 }
 
 func ParseRSAPublicKeyFromPEM(key string) (*rsa.PublicKey, error) {
-	// 检查 key 是不是文件
-	// 如果是则从文件获取 key
-	if fileExists(key) {
-		content, err := ioutil.ReadFile(key)
+	content, err := loadPEMSource(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwtlib.ParseRSAPublicKeyFromPEM(content)
+}
+
+func ParseECPrivateKeyFromPEM(key string) (*ecdsa.PrivateKey, error) {
+	return parseECPrivateKey(key, "")
+}
+
+func parseECPrivateKey(key, passphrase string) (*ecdsa.PrivateKey, error) {
+	content, err := loadPrivateKeyPEM(key, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	// jwtlib 只支持 SEC 1 格式, 解密后的私钥为 PKCS#8 格式
+	if block, _ := pem.Decode(content); block != nil && block.Type == "PRIVATE KEY" {
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
 		if err != nil {
 			return nil, err
 		}
+		priKey, ok := parsed.(*ecdsa.PrivateKey)
+		if !ok {
+			return nil, jwtlib.ErrNotECPrivateKey
+		}
+		return priKey, nil
+	}
+
+	return jwtlib.ParseECPrivateKeyFromPEM(content)
+}
+
+func ParseECPublicKeyFromPEM(key string) (*ecdsa.PublicKey, error) {
+	content, err := loadPEMSource(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwtlib.ParseECPublicKeyFromPEM(content)
+}
+
+// ParseEdPrivateKeyFromPEM 解析 PKCS#8 格式的 Ed25519 私钥
+func ParseEdPrivateKeyFromPEM(key string) (ed25519.PrivateKey, error) {
+	return parseEdPrivateKey(key, "")
+}
+
+func parseEdPrivateKey(key, passphrase string) (ed25519.PrivateKey, error) {
+	content, err := loadPrivateKeyPEM(key, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, jwtlib.ErrKeyMustBePEMEncoded
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	priKey, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, ErrNotEdPrivateKey
+	}
+
+	return priKey, nil
+}
+
+// ParseEdPublicKeyFromPEM 解析 PKIX 格式的 Ed25519 公钥
+func ParseEdPublicKeyFromPEM(key string) (ed25519.PublicKey, error) {
+	content, err := loadPEMSource(key)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, jwtlib.ErrKeyMustBePEMEncoded
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
 
-		key = string(content)
+	pubKey, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, ErrNotEdPublicKey
 	}
 
-	return jwtlib.ParseRSAPublicKeyFromPEM([]byte(key))
+	return pubKey, nil
 }
@@ -8,27 +8,199 @@ import (
 )
 
 // Conf jwt 的配置项
+// 密钥可以直接配置, 也可以使用 file://path 从文件读取或者使用 env://NAME 从环境变量读取
 // JWT:
-//     Secret:
+//     Secret: env://JWT_SECRET
 //     RSAPrivateKey: |
 //         this is private key
 //         multiline str
 //	   RSAPublicKey: |
 //         this is pub key
 //         multiline str
+//     ActiveKeyID: key-2
+//     Keys:
+//       - ID: key-1
+//         RSAPublicKey: |
+//           old pub key
+//       - ID: key-2
+//         RSAPrivateKey: |
+//           new private key
+//       - ID: key-3
+//         RSAPrivateKey: file:///etc/jwt/tls.key
+//         PrivateKeyPassphrase: env://JWT_KEY_PASSPHRASE
+//     KeyReloadInterval: 60
+//     JWKSURL: https://auth.example.com/.well-known/jwks.json
+//     AccessList:
+//       - deny roles suspended
+//       - allow org acme-*
 type Conf struct {
 	// 如果使用 HMAC 需要配置
 	Secret string
 	// RSA 的配置项目, 如果使用 rsa 签名/验签 需要配置
 	RSAPrivateKey string
 	RSAPublicKey  string
-	TokenIssuer   string
+	// ECDSA(P-256/P-384/P-521) 的配置项目, 签名算法根据曲线选择 ES256/ES384/ES512
+	ECPrivateKey string
+	ECPublicKey  string
+	// Ed25519 的配置项目(PKCS#8/PKIX PEM), 签名算法为 EdDSA
+	EdPrivateKey string
+	EdPublicKey  string
+	// 加密的 PKCS#8 私钥的口令, 同样支持 file:// 和 env://
+	PrivateKeyPassphrase string
+	TokenIssuer          string
+	// 使用 Signer.Issue 签发的 token 有效期, 单位秒, 默认 900
+	TokenLifetime int
+	// RefreshTokenManager 签发的 refresh token 有效期, 单位秒, 默认 7 天
+	RefreshTokenLifetime int
+
+	// 多密钥配置, 签发时在 header 写入 kid, 验签时按 kid 选择密钥
+	Keys []KeyConf
+	// 签发使用的密钥 ID, 为空时优先使用上面不带 ID 的密钥, 否则使用 Keys 中的第一个
+	ActiveKeyID string
+	// 检查 file:// 密钥文件是否变化的间隔, 单位秒, 文件变化时重新加载密钥, 为 0 时不检查
+	// 用于 Kubernetes secret 轮换后不需要重启服务
+	KeyReloadInterval int
+
+	// 只验签的服务可以配置签发方的 JWKS 地址, 不需要再配置公钥
+	JWKSURL string
+
+	// 签发使用的算法, 例如 RS256, 必须和签发密钥的类型一致
+	// 为空时根据密钥类型选择 RS512/ES*/EdDSA/HS512
+	SigningMethod string
+	// 验签时允许的算法, 为空时只允许 SigningMethod, 两者都为空时只检查算法和密钥类型是否一致
+	AllowedSigningMethods []string
+
+	// 验签时 iss/aud 必须是其中之一, 为空时不检查
+	ExpectedIssuers   []string
+	ExpectedAudiences []string
+	// 检查 exp, nbf, iat 时允许的时钟偏差, 单位秒
+	Leeway int
+	// 默认拒绝没有 exp 的 token, 为 true 时接受没有 exp 的 token
+	// 没有 exp 的 token 在吊销之前一直有效, 只有兼容旧的签发方时才需要打开
+	AllowMissingExpiration bool
+	// 吊销 token 的存储方式: memory, redis, 为空时不检查吊销
+	// redis 使用 redis.Client, 需要先调用 redis.Connect()
+	Revoker string
+	// 为 true 时不缓存验证通过的 token, 每次都重新验签
+	DisableTokenCache bool
+	// 最多缓存的 token 数量, 默认 10000
+	TokenCacheSize int
+
+	// 访问控制列表, 按顺序检查, 格式为 "<action> <claim> <value> [value...]", 多个 value 用空格分隔
+	// action 为 allow/deny, 为空时只允许 anonymous/guest 角色
+	AccessList []string
+	// 为 true 时不给没有 roles 的 token 添加 anonymous/guest 角色, 此时必须配置 AccessList
+	StrictRoles bool
+	// 为 true 时只记录 AccessList 的检查结果, 不拒绝请求
+	AccessListDryRun bool
+}
+
+// KeyConf 带 ID 的密钥配置, 用于密钥轮换
+type KeyConf struct {
+	ID            string
+	Secret        string
+	RSAPrivateKey string
+	RSAPublicKey  string
+	ECPrivateKey  string
+	ECPublicKey   string
+	EdPrivateKey  string
+	EdPublicKey   string
+	// 加密的 PKCS#8 私钥的口令
+	PrivateKeyPassphrase string
+}
+
+func (k KeyConf) isEmpty() bool {
+	return k.Secret == "" && k.RSAPrivateKey == "" && k.RSAPublicKey == "" &&
+		k.ECPrivateKey == "" && k.ECPublicKey == "" && k.EdPrivateKey == "" && k.EdPublicKey == ""
+}
+
+// activeKey 返回签发使用的密钥配置, 和 jwtOption 中 Keyring.Active 的选择规则一致
+func (c Conf) activeKey() KeyConf {
+	if c.ActiveKeyID != "" {
+		for _, k := range c.Keys {
+			if k.ID == c.ActiveKeyID {
+				return k
+			}
+		}
+	}
+	if legacy := c.legacyKey(); !legacy.isEmpty() || len(c.Keys) == 0 {
+		return legacy
+	}
+	return c.Keys[0]
+}
+
+// supports 检查密钥配置中是否有 family 类型的密钥
+func (k KeyConf) supports(family string) bool {
+	switch family {
+	case "RS":
+		return k.RSAPrivateKey != "" || k.RSAPublicKey != ""
+	case "ES":
+		return k.ECPrivateKey != "" || k.ECPublicKey != ""
+	case "EdDSA":
+		return k.EdPrivateKey != "" || k.EdPublicKey != ""
+	case "HS":
+		return k.Secret != ""
+	default:
+		return false
+	}
+}
+
+func (c Conf) allowedSigningMethods() []string {
+	if len(c.AllowedSigningMethods) > 0 {
+		return c.AllowedSigningMethods
+	}
+	if c.SigningMethod != "" {
+		return []string{c.SigningMethod}
+	}
+	return nil
+}
+
+// legacyKey 返回不带 ID 的顶层密钥配置
+func (c Conf) legacyKey() KeyConf {
+	return KeyConf{
+		Secret:        c.Secret,
+		RSAPrivateKey: c.RSAPrivateKey,
+		RSAPublicKey:  c.RSAPublicKey,
+		ECPrivateKey:  c.ECPrivateKey,
+		ECPublicKey:   c.ECPublicKey,
+		EdPrivateKey:  c.EdPrivateKey,
+		EdPublicKey:   c.EdPublicKey,
+
+		PrivateKeyPassphrase: c.PrivateKeyPassphrase,
+	}
+}
+
+// keyFiles 返回所有 file:// 来源的密钥文件, KeyWatcher 检查这些文件是否变化
+func (c Conf) keyFiles() []string {
+	keys := append([]KeyConf{c.legacyKey()}, c.Keys...)
+
+	files := make([]string, 0)
+	for _, k := range keys {
+		sources := []string{k.Secret, k.RSAPrivateKey, k.RSAPublicKey, k.ECPrivateKey, k.ECPublicKey,
+			k.EdPrivateKey, k.EdPublicKey, k.PrivateKeyPassphrase}
+		for _, source := range sources {
+			if f := keyFile(source); f != "" {
+				files = append(files, f)
+			}
+		}
+	}
+	return files
 }
 
 const (
 	ConfErrCodeNoRequiredKey = iota + 1
 	ConfErrCodeRSAPrivateKey
 	ConfErrCodeRSAPublic
+	ConfErrCodeKeyID
+	ConfErrCodeActiveKeyID
+	ConfErrCodeECPrivateKey
+	ConfErrCodeECPublicKey
+	ConfErrCodeEdPrivateKey
+	ConfErrCodeEdPublicKey
+	ConfErrCodeSigningMethod
+	ConfErrCodeRevoker
+	ConfErrCodeAccessList
+	ConfErrCodeSecret
 )
 
 type ConfErr struct {
@@ -56,13 +228,69 @@ var (
 	ErrNoRequiredSecret    = ConfErr{code: ConfErrCodeNoRequiredKey, message: "没配置 jwt 密钥"}
 	ErrInvaliRSAPrivateKey = ConfErr{code: ConfErrCodeRSAPrivateKey, message: "jwt 私钥配置错误"}
 	ErrInvalidRSAPublicKey = ConfErr{code: ConfErrCodeRSAPublic, message: "jwt 公钥配置错误"}
+	ErrInvalidKeyID        = ConfErr{code: ConfErrCodeKeyID, message: "jwt 密钥 ID 配置错误"}
+	ErrInvalidActiveKeyID  = ConfErr{code: ConfErrCodeActiveKeyID, message: "jwt 签发密钥 ID 不存在"}
+	ErrInvalidECPrivateKey = ConfErr{code: ConfErrCodeECPrivateKey, message: "jwt ECDSA 私钥配置错误"}
+	ErrInvalidECPublicKey  = ConfErr{code: ConfErrCodeECPublicKey, message: "jwt ECDSA 公钥配置错误"}
+	ErrInvalidEdPrivateKey = ConfErr{code: ConfErrCodeEdPrivateKey, message: "jwt Ed25519 私钥配置错误"}
+	ErrInvalidEdPublicKey  = ConfErr{code: ConfErrCodeEdPublicKey, message: "jwt Ed25519 公钥配置错误"}
+
+	ErrInvalidSigningMethodConf = ConfErr{code: ConfErrCodeSigningMethod, message: "jwt 签名算法配置错误"}
+	ErrInvalidRevokerConf       = ConfErr{code: ConfErrCodeRevoker, message: "jwt 吊销存储配置错误"}
+	ErrInvalidAccessListConf    = ConfErr{code: ConfErrCodeAccessList, message: "jwt 访问控制列表配置错误"}
+	ErrInvalidSecretConf        = ConfErr{code: ConfErrCodeSecret, message: "jwt hmac 密钥配置错误"}
 )
 
 func (c Conf) Validate() error {
-	if c.Secret == "" && !(c.RSAPrivateKey != "" || c.RSAPublicKey != "") {
+	if c.legacyKey().isEmpty() && len(c.Keys) == 0 && c.JWKSURL == "" {
 		return ErrNoRequiredSecret
 	}
 
+	ids := make(map[string]struct{}, len(c.Keys))
+	for _, k := range c.Keys {
+		if k.ID == "" {
+			return ErrInvalidKeyID.WithMessage("empty id")
+		}
+		if _, exists := ids[k.ID]; exists {
+			return ErrInvalidKeyID.WithMessage(k.ID)
+		}
+		if k.isEmpty() {
+			return ErrNoRequiredSecret.WithMessage(k.ID)
+		}
+		ids[k.ID] = struct{}{}
+	}
+
+	if c.ActiveKeyID != "" {
+		if _, exists := ids[c.ActiveKeyID]; !exists {
+			return ErrInvalidActiveKeyID.WithMessage(c.ActiveKeyID)
+		}
+	}
+
+	if c.SigningMethod != "" {
+		family := SigningMethod(c.SigningMethod).family()
+		if family == "" {
+			return ErrInvalidSigningMethodConf.WithMessage(c.SigningMethod)
+		}
+		// 只配置了 JWKSURL 时没有本地密钥可以检查
+		if active := c.activeKey(); !active.isEmpty() && !active.supports(family) {
+			return ErrInvalidSigningMethodConf.WithMessage(c.SigningMethod)
+		}
+	}
+
+	if c.Revoker != "" && c.Revoker != RevokerMemory && c.Revoker != RevokerRedis {
+		return ErrInvalidRevokerConf.WithMessage(c.Revoker)
+	}
+
+	for _, m := range c.AllowedSigningMethods {
+		if SigningMethod(m).family() == "" {
+			return ErrInvalidSigningMethodConf.WithMessage(m)
+		}
+	}
+
+	if _, err := c.accessList(); err != nil {
+		return err
+	}
+
 	// if c.RSAPrivateKey != "" {
 	// 	_, err := ParseRSAPrivateKeyFromPEM(c.RSAPrivateKey)
 	// 	if err != nil {
@@ -80,6 +308,26 @@ func (c Conf) Validate() error {
 	return nil
 }
 
+// accessList 解析 AccessList, 没有配置时使用只允许 anonymous/guest 角色的默认规则
+func (c Conf) accessList() ([]*AccessListEntry, error) {
+	if len(c.AccessList) == 0 {
+		if c.StrictRoles {
+			return nil, ErrInvalidAccessListConf.WithMessage("strict roles requires access list")
+		}
+		return newAccessList()
+	}
+
+	accessList := make([]*AccessListEntry, 0, len(c.AccessList))
+	for _, s := range c.AccessList {
+		entry, err := ParseAccessListEntry(s)
+		if err != nil {
+			return nil, ErrInvalidAccessListConf.WithMessage(s + ": " + err.Error())
+		}
+		accessList = append(accessList, entry)
+	}
+	return accessList, nil
+}
+
 // C 默认配置项目, 调用方可以直接引用
 // warn(joe@2019/11/19): 默认的配置被设计为只能用于资源请求接口授权验证, 用作其他用途可能带来未知的安全风险
 // var Default = Config{Jwt: &jwt.C}
@@ -96,4 +344,70 @@ func BindPflag(flagSet *pflag.FlagSet, keyPrefix string) {
 
 	flagSet.String("jwt_rsa_public_key", "", "jwt rsa public key")
 	_ = viper.BindPFlag(keyPrefix+".RSAPublicKey", flagSet.Lookup("jwt_rsa_public_key"))
+
+	flagSet.String("jwt_ec_private_key", "", "jwt ecdsa private key")
+	_ = viper.BindPFlag(keyPrefix+".ECPrivateKey", flagSet.Lookup("jwt_ec_private_key"))
+
+	flagSet.String("jwt_ec_public_key", "", "jwt ecdsa public key")
+	_ = viper.BindPFlag(keyPrefix+".ECPublicKey", flagSet.Lookup("jwt_ec_public_key"))
+
+	flagSet.String("jwt_ed_private_key", "", "jwt ed25519 private key")
+	_ = viper.BindPFlag(keyPrefix+".EdPrivateKey", flagSet.Lookup("jwt_ed_private_key"))
+
+	flagSet.String("jwt_ed_public_key", "", "jwt ed25519 public key")
+	_ = viper.BindPFlag(keyPrefix+".EdPublicKey", flagSet.Lookup("jwt_ed_public_key"))
+
+	flagSet.String("jwt_private_key_passphrase", "", "jwt encrypted pkcs#8 private key passphrase")
+	_ = viper.BindPFlag(keyPrefix+".PrivateKeyPassphrase", flagSet.Lookup("jwt_private_key_passphrase"))
+
+	flagSet.Int("jwt_key_reload_interval", 0, "interval in seconds to reload jwt keys from file:// sources, 0 disables")
+	_ = viper.BindPFlag(keyPrefix+".KeyReloadInterval", flagSet.Lookup("jwt_key_reload_interval"))
+
+	flagSet.String("jwt_active_key_id", "", "jwt signing key id")
+	_ = viper.BindPFlag(keyPrefix+".ActiveKeyID", flagSet.Lookup("jwt_active_key_id"))
+
+	flagSet.Int("jwt_token_lifetime", defaultTokenLifetime, "jwt token lifetime in seconds")
+	_ = viper.BindPFlag(keyPrefix+".TokenLifetime", flagSet.Lookup("jwt_token_lifetime"))
+
+	flagSet.Int("jwt_refresh_token_lifetime", defaultRefreshTokenLifetime, "jwt refresh token lifetime in seconds")
+	_ = viper.BindPFlag(keyPrefix+".RefreshTokenLifetime", flagSet.Lookup("jwt_refresh_token_lifetime"))
+
+	flagSet.String("jwt_signing_method", "", "jwt signing method, eg: RS256")
+	_ = viper.BindPFlag(keyPrefix+".SigningMethod", flagSet.Lookup("jwt_signing_method"))
+
+	flagSet.StringSlice("jwt_allowed_signing_methods", nil, "jwt signing methods allowed when verifying tokens")
+	_ = viper.BindPFlag(keyPrefix+".AllowedSigningMethods", flagSet.Lookup("jwt_allowed_signing_methods"))
+
+	flagSet.StringSlice("jwt_expected_issuers", nil, "jwt issuers accepted when verifying tokens")
+	_ = viper.BindPFlag(keyPrefix+".ExpectedIssuers", flagSet.Lookup("jwt_expected_issuers"))
+
+	flagSet.StringSlice("jwt_expected_audiences", nil, "jwt audiences accepted when verifying tokens")
+	_ = viper.BindPFlag(keyPrefix+".ExpectedAudiences", flagSet.Lookup("jwt_expected_audiences"))
+
+	flagSet.Int("jwt_leeway", 0, "jwt clock skew leeway in seconds")
+	_ = viper.BindPFlag(keyPrefix+".Leeway", flagSet.Lookup("jwt_leeway"))
+
+	flagSet.Bool("jwt_allow_missing_expiration", false, "accept jwt without exp claim")
+	_ = viper.BindPFlag(keyPrefix+".AllowMissingExpiration", flagSet.Lookup("jwt_allow_missing_expiration"))
+
+	flagSet.String("jwt_revoker", "", "jwt revoked token store: memory, redis")
+	_ = viper.BindPFlag(keyPrefix+".Revoker", flagSet.Lookup("jwt_revoker"))
+
+	flagSet.Bool("jwt_disable_token_cache", false, "disable caching of validated jwt")
+	_ = viper.BindPFlag(keyPrefix+".DisableTokenCache", flagSet.Lookup("jwt_disable_token_cache"))
+
+	flagSet.Int("jwt_token_cache_size", defaultTokenCacheMaxEntries, "max number of cached jwt")
+	_ = viper.BindPFlag(keyPrefix+".TokenCacheSize", flagSet.Lookup("jwt_token_cache_size"))
+
+	flagSet.StringSlice("jwt_access_list", nil, "jwt access list entries, eg: \"deny roles suspended,allow roles admin editor\"")
+	_ = viper.BindPFlag(keyPrefix+".AccessList", flagSet.Lookup("jwt_access_list"))
+
+	flagSet.Bool("jwt_strict_roles", false, "do not add default anonymous/guest roles to jwt without roles")
+	_ = viper.BindPFlag(keyPrefix+".StrictRoles", flagSet.Lookup("jwt_strict_roles"))
+
+	flagSet.Bool("jwt_access_list_dry_run", false, "log jwt access list decisions without enforcing them")
+	_ = viper.BindPFlag(keyPrefix+".AccessListDryRun", flagSet.Lookup("jwt_access_list_dry_run"))
+
+	flagSet.String("jwt_jwks_url", "", "jwt jwks url for verifying tokens")
+	_ = viper.BindPFlag(keyPrefix+".JWKSURL", flagSet.Lookup("jwt_jwks_url"))
 }
@@ -0,0 +1,55 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+)
+
+// EdDSA Errors
+const (
+	ErrNotEdPrivateKey strError = "key is not a valid Ed25519 private key"
+	ErrNotEdPublicKey  strError = "key is not a valid Ed25519 public key"
+)
+
+// signingMethodEdDSA 实现 RFC 8037 中的 EdDSA(Ed25519) 签名, jwt-go v3 没有内置
+type signingMethodEdDSA struct{}
+
+func init() {
+	jwtlib.RegisterSigningMethod(string(SigningMethodEdDSA), func() jwtlib.SigningMethod {
+		return &signingMethodEdDSA{}
+	})
+}
+
+func (m *signingMethodEdDSA) Alg() string {
+	return string(SigningMethodEdDSA)
+}
+
+// Verify 的 key 必须是 ed25519.PublicKey
+func (m *signingMethodEdDSA) Verify(signingString, signature string, key interface{}) error {
+	pubKey, ok := key.(ed25519.PublicKey)
+	if !ok || len(pubKey) != ed25519.PublicKeySize {
+		return jwtlib.ErrInvalidKeyType
+	}
+
+	sig, err := jwtlib.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pubKey, []byte(signingString), sig) {
+		return jwtlib.ErrSignatureInvalid
+	}
+
+	return nil
+}
+
+// Sign 的 key 必须是 ed25519.PrivateKey
+func (m *signingMethodEdDSA) Sign(signingString string, key interface{}) (string, error) {
+	priKey, ok := key.(ed25519.PrivateKey)
+	if !ok || len(priKey) != ed25519.PrivateKeySize {
+		return "", jwtlib.ErrInvalidKeyType
+	}
+
+	return jwtlib.EncodeSegment(ed25519.Sign(priKey, []byte(signingString))), nil
+}
@@ -0,0 +1,142 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newECKeyPairPEM(t *testing.T, curve elliptic.Curve) [2]string {
+	priKey, err := ecdsa.GenerateKey(curve, rand.Reader)
+	assert.NoError(t, err)
+
+	priDER, err := x509.MarshalECPrivateKey(priKey)
+	assert.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(&priKey.PublicKey)
+	assert.NoError(t, err)
+
+	return [2]string{
+		string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: priDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+	}
+}
+
+func newEdKeyPairPEM(t *testing.T) [2]string {
+	pubKey, priKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	priDER, err := x509.MarshalPKCS8PrivateKey(priKey)
+	assert.NoError(t, err)
+	pubDER, err := x509.MarshalPKIXPublicKey(pubKey)
+	assert.NoError(t, err)
+
+	return [2]string{
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: priDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})),
+	}
+}
+
+func TestSignerImpl_Sign_ECAndEd(t *testing.T) {
+	p256 := newECKeyPairPEM(t, elliptic.P256())
+	p384 := newECKeyPairPEM(t, elliptic.P384())
+	ed := newEdKeyPairPEM(t)
+
+	cases := []struct {
+		caseName   string
+		signConf   Conf
+		verifyConf Conf
+		alg        string
+	}{
+		{"ES256", Conf{ECPrivateKey: p256[0]}, Conf{ECPublicKey: p256[1]}, "ES256"},
+		{"ES384", Conf{ECPrivateKey: p384[0]}, Conf{ECPublicKey: p384[1]}, "ES384"},
+		{"EdDSA", Conf{EdPrivateKey: ed[0]}, Conf{EdPublicKey: ed[1]}, "EdDSA"},
+		{
+			"EdDSA with kid",
+			Conf{Keys: []KeyConf{{ID: "ed", EdPrivateKey: ed[0]}}},
+			Conf{Keys: []KeyConf{{ID: "ed", EdPublicKey: ed[1]}}},
+			"EdDSA",
+		},
+	}
+
+	claims := UserClaims{ID: "123456", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	for _, tc := range cases {
+		t.Logf("case: %s", tc.caseName)
+
+		signer, err := NewSignerImplWithConf(tc.signConf)
+		assert.NoError(t, err)
+		token, err := signer.Sign(claims)
+		assert.NoError(t, err)
+		assert.Equal(t, tc.alg, tokenHeader(t, token)["alg"])
+
+		validator, err := NewValidatorImplWithConf(tc.verifyConf)
+		assert.NoError(t, err)
+		verified, err := validator.Verify(token, nil)
+		assert.NoError(t, err)
+		if assert.NotNil(t, verified) {
+			assert.Equal(t, claims.ID, verified.ID)
+		}
+
+		// 其他算法的密钥无法验证
+		other, err := NewValidatorImplWithConf(Conf{RSAPublicKey: rsaKeyPair1[1]})
+		assert.NoError(t, err)
+		_, err = other.Verify(token, nil)
+		assert.Error(t, err)
+	}
+}
+
+func TestConf_Validate_ECAndEd(t *testing.T) {
+	cases := []struct {
+		conf Conf
+		code int
+	}{
+		{Conf{ECPrivateKey: "invalid ec private key"}, ConfErrCodeECPrivateKey},
+		{Conf{ECPublicKey: "invalid ec public key"}, ConfErrCodeECPublicKey},
+		{Conf{EdPrivateKey: "invalid ed private key"}, ConfErrCodeEdPrivateKey},
+		{Conf{EdPublicKey: rsaKeyPair1[1]}, ConfErrCodeEdPublicKey},
+	}
+
+	for _, tc := range cases {
+		_, err := newJwtOption(tc.conf)
+		// nolint(errorlint): fixme
+		e, ok := err.(ConfErr)
+		if assert.True(t, ok, "%v", err) {
+			assert.Equal(t, tc.code, e.Code())
+		}
+	}
+}
+
+func TestJWKS_ECAndEd(t *testing.T) {
+	p256 := newECKeyPairPEM(t, elliptic.P256())
+	ed := newEdKeyPairPEM(t)
+
+	option, err := newJwtOption(Conf{Keys: []KeyConf{
+		{ID: "ec", ECPrivateKey: p256[0]},
+		{ID: "ed", EdPrivateKey: ed[0]},
+	}})
+	assert.NoError(t, err)
+
+	set := option.keyring.JWKS()
+	if !assert.Len(t, set.Keys, 2) {
+		return
+	}
+	assert.Equal(t, "EC", set.Keys[0].Kty)
+	assert.Equal(t, "ES256", set.Keys[0].Alg)
+	assert.Equal(t, "OKP", set.Keys[1].Kty)
+
+	ecPub, err := set.Keys[0].PublicKey()
+	assert.NoError(t, err)
+	expectedEC, _ := ParseECPublicKeyFromPEM(p256[1])
+	assert.True(t, expectedEC.Equal(ecPub))
+
+	edPub, err := set.Keys[1].PublicKey()
+	assert.NoError(t, err)
+	expectedEd, _ := ParseEdPublicKeyFromPEM(ed[1])
+	assert.Equal(t, expectedEd, edPub)
+}
@@ -3,6 +3,8 @@ package jwt
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
 	jwtlib "github.com/dgrijalva/jwt-go"
 )
@@ -47,36 +49,82 @@ func (e fmtErr) Error() string { return fmt.Sprintf(e.err.Error(), e.v...) }
 // Unwrap is a method to help unwrap errors on the base error for go1.13+
 func (e fmtErr) Unwrap() error { return errors.Unwrap(e.err) }
 
+// translateJwtValidationError 返回 DefaultLocale 的提示, 同时有多个错误位时使用最具体的错误类型
 func translateJwtValidationError(err *jwtlib.ValidationError) string {
-	var jwtValidationErrDisplay = map[uint32]string{
-		// Signature validation failed
-		jwtlib.ValidationErrorMalformed: "令牌格式错误",
-		// Token could not be verified because of signing problems
-		jwtlib.ValidationErrorUnverifiable: "令牌签名错误无法验证",
-		// Signature validation failed
-		jwtlib.ValidationErrorSignatureInvalid: "签名验证失败",
-
-		// Standard Claim validation errors
-		// AUD validation failed
-		jwtlib.ValidationErrorAudience: "受众验证失败",
-		// EXP validation failed
-		jwtlib.ValidationErrorExpired: "令牌已过期",
-		// IAT validation failed
-		jwtlib.ValidationErrorIssuedAt: "发布时间验证失败",
-		// ISS validation failed
-		jwtlib.ValidationErrorIssuer: "申请人验证失败",
-		// NBF validation failed
-		jwtlib.ValidationErrorNotValidYet: "令牌尚未生效",
-		// JTI validation failed
-		jwtlib.ValidationErrorId: "JTI 验证失败",
-		// Generic claims validation error
-		jwtlib.ValidationErrorClaimsInvalid: "通用声明验证错误",
+	if err == nil {
+		return Messages.Message(DefaultLocale, ErrTokenInvalid)
 	}
+	return Messages.Translate(DefaultLocale, err)
+}
+
+// multiError 多个 TokenBackend 验签失败的错误, 按 errorSpecificity 从高到低排列
+// errors.Is 和 errors.As 检查其中的每一个错误
+type multiError []error
+
+// newMultiError 只有一个错误时直接返回该错误
+func newMultiError(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	sorted := append(multiError(nil), errs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return errorSpecificity(sorted[i]) > errorSpecificity(sorted[j])
+	})
+	return sorted
+}
+
+func (e multiError) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target.
+func (e multiError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+		// *jwtlib.ValidationError 没有实现 Unwrap
+		var vErr *jwtlib.ValidationError
+		if errors.As(err, &vErr) && vErr != nil && vErr.Inner != nil && errors.Is(vErr.Inner, target) {
+			return true
+		}
+	}
+	return false
+}
 
-	s, ok := jwtValidationErrDisplay[err.Errors]
-	if ok {
-		return s
+// As finds the first error that matches target.
+func (e multiError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
 	}
+	return false
+}
 
-	return "令牌错误"
+// errorSpecificity 错误的具体程度
+// 未知的 kid 说明没有可以验签的密钥, 比其他 backend 的签名错误更具体,
+// 无法取得验签密钥(例如签名算法不匹配)的错误最不具体
+func errorSpecificity(err error) int {
+	var vErr *jwtlib.ValidationError
+	if errors.As(err, &vErr) && vErr != nil {
+		// jwtlib 把 ProvideKey 返回的错误放在 Inner 中
+		if vErr.Inner != nil && errors.Is(vErr.Inner, ErrUnexpectedKID) {
+			return 2
+		}
+		if vErr.Errors == jwtlib.ValidationErrorUnverifiable {
+			return 0
+		}
+	}
+	if errors.Is(err, ErrUnexpectedKID) {
+		return 2
+	}
+	return 1
 }
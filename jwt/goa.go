@@ -0,0 +1,23 @@
+package jwt
+
+import (
+	"errors"
+
+	goa "goa.design/goa/v3/pkg"
+)
+
+// legacyGoaError 旧版 Verify 返回的 goa unauthorized 错误, 新代码使用 VerifyContext 和 goajwt
+func legacyGoaError(err error) error {
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) {
+		return err
+	}
+	return UnauthorizedErr(Messages.Message(DefaultLocale, verifyErr.Kind))
+}
+
+// UnauthorizedErr 返回 goa 的 unauthorized 错误
+//
+// Deprecated: 使用 goajwt.UnauthorizedErr
+func UnauthorizedErr(format string, args ...interface{}) error {
+	return goa.TemporaryError("unauthorized", format, args...)
+}
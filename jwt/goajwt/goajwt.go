@@ -0,0 +1,40 @@
+// Package goajwt 把 jwt.VerifyContext 返回的错误转换为 goa 的 unauthorized 错误
+// jwt 包本身不依赖 goa 的错误类型, 在 goa 服务中使用 jwt 时通过这个包适配
+//
+//	claims, err := auth.VerifyContext(ctx, token)
+//	if err != nil {
+//		return ctx, goajwt.ErrorContext(ctx, err)
+//	}
+package goajwt
+
+import (
+	"context"
+	"errors"
+
+	goa "goa.design/goa/v3/pkg"
+
+	"github.com/geeksmy/go-libs/jwt"
+)
+
+// Error 把 VerifyContext 返回的错误转换为 goa 的 unauthorized 错误, 提示使用 jwt.DefaultLocale, 其他错误原样返回
+func Error(err error) error {
+	return goaError(jwt.DefaultLocale, err)
+}
+
+// ErrorContext 和 Error 相同, 提示使用 jwt.LocaleResolver 从 ctx 中选择的语言
+func ErrorContext(ctx context.Context, err error) error {
+	return goaError(jwt.LocaleResolver(ctx), err)
+}
+
+func goaError(locale string, err error) error {
+	var verifyErr *jwt.VerifyError
+	if !errors.As(err, &verifyErr) {
+		return err
+	}
+	return UnauthorizedErr(jwt.Messages.Message(locale, verifyErr.Kind))
+}
+
+// UnauthorizedErr 返回 goa 的 unauthorized 错误
+func UnauthorizedErr(format string, args ...interface{}) error {
+	return goa.TemporaryError("unauthorized", format, args...)
+}
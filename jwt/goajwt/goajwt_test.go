@@ -0,0 +1,46 @@
+package goajwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	goa "goa.design/goa/v3/pkg"
+
+	"github.com/geeksmy/go-libs/jwt"
+)
+
+func TestError(t *testing.T) {
+	other := errors.New("other")
+	assert.Equal(t, other, Error(other))
+	assert.Nil(t, Error(nil))
+
+	var serviceErr *goa.ServiceError
+	err := Error(&jwt.VerifyError{Kind: jwt.ErrTokenRevoked})
+	if assert.True(t, errors.As(err, &serviceErr)) {
+		assert.Equal(t, "unauthorized", serviceErr.Name)
+		assert.Equal(t, "令牌已被吊销", serviceErr.Message)
+	}
+}
+
+func TestErrorContext(t *testing.T) {
+	err := &jwt.VerifyError{Kind: jwt.ErrTokenExpired}
+
+	// nolint(errorlint): goa 要求 *goa.ServiceError
+	goaErr := ErrorContext(jwt.WithLocale(context.Background(), jwt.LocaleEn), err).(*goa.ServiceError)
+	assert.Equal(t, "unauthorized", goaErr.Name)
+	assert.Equal(t, "token is expired", goaErr.Message)
+
+	// nolint(errorlint): goa 要求 *goa.ServiceError
+	goaErr = ErrorContext(context.Background(), err).(*goa.ServiceError)
+	assert.Equal(t, "令牌已过期", goaErr.Message)
+
+	resolver := jwt.LocaleResolver
+	defer func() { jwt.LocaleResolver = resolver }()
+	jwt.LocaleResolver = func(context.Context) string { return jwt.LocaleEn }
+
+	// nolint(errorlint): goa 要求 *goa.ServiceError
+	goaErr = ErrorContext(context.Background(), err).(*goa.ServiceError)
+	assert.Equal(t, "token is expired", goaErr.Message)
+}
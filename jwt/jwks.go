@@ -0,0 +1,404 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+)
+
+// JWKS Errors
+const (
+	ErrEmptyJWKSURL        strError = "empty jwks url"
+	ErrJWKSFetch           strError = "failed to fetch jwks: %v"
+	ErrJWKSStatus          strError = "failed to fetch jwks: unexpected status %d"
+	ErrUnsupportedJWKType  strError = "unsupported jwk key type: %s"
+	ErrInvalidJWK          strError = "invalid jwk %q: %v"
+	ErrAmbiguousJWKSNoKID  strError = "token has no kid and jwks contains %d keys"
+	ErrNoPublicKeysForJWKS strError = "keyring has no public keys to publish"
+)
+
+const (
+	defaultJWKSCacheTTL           = time.Hour
+	defaultJWKSMinRefreshInterval = time.Minute
+	defaultJWKSFetchTimeout       = time.Second * 10
+	// maxJWKSBodySize 远程 JWKS 响应的大小上限
+	maxJWKSBodySize = 1 << 20
+	// minJWKSRSAKeyBits 远程 JWKS 中 RSA 公钥的最小长度
+	minJWKSRSAKeyBits = 2048
+)
+
+// JSONWebKey RFC 7517 中的 JWK, 只包含公钥部分
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet RFC 7517 中的 JWK Set
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS 返回 keyring 中所有公钥组成的 JWK Set, HMAC 密钥不会被公开
+func (r *Keyring) JWKS() JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, k := range r.keys {
+		if pub := k.rsaPublicKey(); pub != nil {
+			set.Keys = append(set.Keys, newRSAJSONWebKey(k.ID, pub))
+		}
+		if pub := k.ecPublicKey(); pub != nil {
+			set.Keys = append(set.Keys, newECJSONWebKey(k.ID, pub))
+		}
+		if pub := k.edPublicKey(); pub != nil {
+			set.Keys = append(set.Keys, newEdJSONWebKey(k.ID, pub))
+		}
+	}
+	return set
+}
+
+func newRSAJSONWebKey(kid string, pub *rsa.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+}
+
+func newECJSONWebKey(kid string, pub *ecdsa.PublicKey) JSONWebKey {
+	params := pub.Curve.Params()
+	size := (params.BitSize + 7) / 8
+	method, _ := ecSigningMethod(pub.Curve)
+	return JSONWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Use: "sig",
+		Alg: string(method),
+		Crv: params.Name,
+		X:   base64.RawURLEncoding.EncodeToString(padBytes(pub.X.Bytes(), size)),
+		Y:   base64.RawURLEncoding.EncodeToString(padBytes(pub.Y.Bytes(), size)),
+	}
+}
+
+func newEdJSONWebKey(kid string, pub ed25519.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "OKP",
+		Kid: kid,
+		Use: "sig",
+		Alg: string(SigningMethodEdDSA),
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(pub),
+	}
+}
+
+// padBytes 在左侧补零到 size 字节, RFC 7518 要求 EC 坐标为定长
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
+
+func jwkCurve(name string) (elliptic.Curve, bool) {
+	switch name {
+	case "P-256":
+		return elliptic.P256(), true
+	case "P-384":
+		return elliptic.P384(), true
+	case "P-521":
+		return elliptic.P521(), true
+	default:
+		return nil, false
+	}
+}
+
+// PublicKey 把 JWK 解析为验签使用的公钥
+func (k JSONWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, err)
+		}
+		if len(n) == 0 || len(e) == 0 {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, "empty modulus or exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		curve, ok := jwkCurve(k.Crv)
+		if !ok {
+			return nil, ErrUnsupportedCurve.WithArgs(k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, err)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, "point is not on curve")
+		}
+		return pub, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, ErrUnsupportedCurve.WithArgs(k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, err)
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, ErrInvalidJWK.WithArgs(k.Kid, "invalid Ed25519 public key size")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, ErrUnsupportedJWKType.WithArgs(k.Kty)
+	}
+}
+
+// NewJWKSHandler 返回对外发布 keyring 公钥的 http.Handler, 一般挂载在 /.well-known/jwks.json
+// keyring 不会变化, 需要跟随密钥重新加载时使用 (*Authenticator).JWKSHandler
+func NewJWKSHandler(keyring *Keyring) (http.Handler, error) {
+	if keyring == nil {
+		return nil, ErrEmptyKeyring
+	}
+	if len(keyring.JWKS().Keys) == 0 {
+		return nil, ErrNoPublicKeysForJWKS
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveJWKS(w, r, keyring)
+	}), nil
+}
+
+// serveJWKS 每次请求都使用 keyring 当前的公钥生成 body
+func serveJWKS(w http.ResponseWriter, r *http.Request, keyring *Keyring) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if keyring != nil {
+		set = keyring.JWKS()
+	}
+	body, err := json.Marshal(set)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	_, _ = w.Write(body)
+}
+
+// NewJWKSHandlerWithConf 使用指定配置创建 JWKS handler, 不会跟随密钥重新加载
+func NewJWKSHandlerWithConf(c Conf) (http.Handler, error) {
+	option, err := newJwtOption(c)
+	if err != nil {
+		return nil, err
+	}
+	return NewJWKSHandler(option.keyring)
+}
+
+// JWKSTokenBackend 从远程 JWKS 地址获取验签公钥
+// 公钥会缓存 CacheTTL, 遇到未知的 kid 时会重新拉取, 两次拉取间隔不小于 MinRefreshInterval
+type JWKSTokenBackend struct {
+	URL                string
+	Client             *http.Client
+	CacheTTL           time.Duration
+	MinRefreshInterval time.Duration
+
+	// refreshMu 保证同时只有一个请求在拉取 JWKS, 拉取期间不持有 mu, 不影响缓存中公钥的查找
+	refreshMu sync.Mutex
+	mu        sync.RWMutex
+	keys      map[string]*Key
+	fetchedAt time.Time
+}
+
+// NewJWKSTokenBackend returns JWKSTokenBackend instance.
+func NewJWKSTokenBackend(url string) (*JWKSTokenBackend, error) {
+	if url == "" {
+		return nil, ErrEmptyJWKSURL
+	}
+	b := &JWKSTokenBackend{
+		URL:                url,
+		Client:             &http.Client{Timeout: defaultJWKSFetchTimeout},
+		CacheTTL:           defaultJWKSCacheTTL,
+		MinRefreshInterval: defaultJWKSMinRefreshInterval,
+	}
+	return b, nil
+}
+
+// ProvideKey provides key material from JWKSTokenBackend.
+func (b *JWKSTokenBackend) ProvideKey(token *jwtlib.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, found, stale := b.lookup(kid)
+	if !found || stale {
+		if err := b.refresh(); err != nil && !found {
+			return nil, err
+		}
+		key, found, _ = b.lookup(kid)
+	}
+	if !found {
+		if kid == "" {
+			return nil, ErrAmbiguousJWKSNoKID.WithArgs(b.size())
+		}
+		return nil, ErrUnexpectedKID
+	}
+
+	return key.verifyKey(token)
+}
+
+// lookup 在缓存中查找公钥, 没有 kid 时只有在 JWKS 中仅有一个公钥的情况下才能确定
+func (b *JWKSTokenBackend) lookup(kid string) (*Key, bool, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	stale := time.Since(b.fetchedAt) > b.CacheTTL
+	if kid == "" {
+		if len(b.keys) != 1 {
+			return nil, false, stale
+		}
+		for _, key := range b.keys {
+			return key, true, stale
+		}
+	}
+
+	key, ok := b.keys[kid]
+	return key, ok, stale
+}
+
+func (b *JWKSTokenBackend) size() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.keys)
+}
+
+// refresh 重新拉取 JWKS, 距离上次拉取不足 MinRefreshInterval 时直接返回
+// 等待其他请求拉取完成的调用会直接使用刚拉取的结果
+func (b *JWKSTokenBackend) refresh() error {
+	b.refreshMu.Lock()
+	defer b.refreshMu.Unlock()
+
+	b.mu.RLock()
+	fetchedAt := b.fetchedAt
+	b.mu.RUnlock()
+	if !fetchedAt.IsZero() && time.Since(fetchedAt) < b.MinRefreshInterval {
+		return nil
+	}
+
+	keys, err := b.fetch()
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.keys = keys
+	b.fetchedAt = time.Now()
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *JWKSTokenBackend) fetch() (map[string]*Key, error) {
+	resp, err := b.Client.Get(b.URL)
+	if err != nil {
+		return nil, ErrJWKSFetch.WithArgs(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrJWKSStatus.WithArgs(resp.StatusCode)
+	}
+
+	var set JSONWebKeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxJWKSBodySize)).Decode(&set); err != nil {
+		return nil, ErrJWKSFetch.WithArgs(err)
+	}
+
+	keys := make(map[string]*Key, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, ok := newKeyFromJWK(jwk)
+		if !ok {
+			// 忽略不支持或者不安全的密钥, 保证其他密钥可用
+			continue
+		}
+		// 同一个 kid 对应多个公钥时无法确定使用哪一个
+		if _, exists := keys[jwk.Kid]; exists {
+			return nil, ErrDuplicateKeyID.WithArgs(jwk.Kid)
+		}
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+// newKeyFromJWK 把 JWK 转换为验签使用的 Key, JWK 中的 alg 和密钥不一致或者 RSA 公钥短于 2048 位时返回 false
+func newKeyFromJWK(jwk JSONWebKey) (*Key, bool) {
+	pub, err := jwk.PublicKey()
+	if err != nil {
+		return nil, false
+	}
+
+	alg := SigningMethod(jwk.Alg)
+	key := &Key{ID: jwk.Kid, Algorithm: alg}
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minJWKSRSAKeyBits || (alg != "" && alg.family() != "RS") {
+			return nil, false
+		}
+		key.PublicKey = pub
+	case *ecdsa.PublicKey:
+		if method, err := ecSigningMethod(pub.Curve); err != nil || (alg != "" && alg != method) {
+			return nil, false
+		}
+		key.ECPublicKey = pub
+	case ed25519.PublicKey:
+		if alg != "" && alg != SigningMethodEdDSA {
+			return nil, false
+		}
+		key.EdPublicKey = pub
+	default:
+		return nil, false
+	}
+	return key, true
+}
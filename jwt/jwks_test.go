@@ -0,0 +1,267 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// testJWKSKey JWKSTokenBackend 只接受 2048 位以上的 RSA 公钥
+var testJWKSKey = [2]string{"file://tests/jwt.key", "file://tests/jwt.key.pub"}
+
+func TestNewJWKSHandler(t *testing.T) {
+	handler, err := NewJWKSHandlerWithConf(Conf{
+		Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM",
+		Keys: []KeyConf{
+			{ID: "key-1", RSAPublicKey: rsaKeyPair1[1]},
+			{ID: "key-2", RSAPrivateKey: rsaKeyPair2[0]},
+		},
+	})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var set JSONWebKeySet
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+	// HMAC 密钥不会被发布
+	if assert.Len(t, set.Keys, 2) {
+		assert.Equal(t, "key-1", set.Keys[0].Kid)
+		assert.Equal(t, "key-2", set.Keys[1].Kid)
+		assert.Equal(t, "RSA", set.Keys[1].Kty)
+	}
+
+	pub, err := set.Keys[0].PublicKey()
+	assert.NoError(t, err)
+	expected, _ := ParseRSAPublicKeyFromPEM(rsaKeyPair1[1])
+	assert.Equal(t, expected, pub)
+
+	_, err = NewJWKSHandlerWithConf(Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"})
+	assert.True(t, errors.Is(err, ErrNoPublicKeysForJWKS))
+}
+
+func TestJWKSTokenBackend(t *testing.T) {
+	issuerConf := Conf{
+		ActiveKeyID: "key-1",
+		Keys: []KeyConf{
+			{ID: "key-1", RSAPrivateKey: testJWKSKey[0]},
+		},
+	}
+
+	var (
+		handler  atomic.Value
+		requests int32
+	)
+	setIssuerConf := func(c Conf) {
+		h, err := NewJWKSHandlerWithConf(c)
+		assert.NoError(t, err)
+		handler.Store(h)
+	}
+	setIssuerConf(issuerConf)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler.Load().(http.Handler).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	validator, err := NewValidatorImplWithConf(Conf{JWKSURL: server.URL})
+	assert.NoError(t, err)
+
+	claims := UserClaims{ID: "123456", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	signer, err := NewSignerImplWithConf(issuerConf)
+	assert.NoError(t, err)
+	token, err := signer.Sign(claims)
+	assert.NoError(t, err)
+
+	_, err = validator.Verify(token, nil)
+	assert.NoError(t, err)
+	_, err = validator.Verify(token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "jwks should be cached")
+
+	// 签发方轮换密钥, 未知 kid 触发重新拉取
+	issuerConf = Conf{
+		ActiveKeyID: "key-2",
+		Keys: []KeyConf{
+			{ID: "key-1", RSAPublicKey: testJWKSKey[1]},
+			{ID: "key-2", RSAPrivateKey: rsaKeyPair3[0]},
+		},
+	}
+	setIssuerConf(issuerConf)
+	signer, err = NewSignerImplWithConf(issuerConf)
+	assert.NoError(t, err)
+	rotated, err := signer.Sign(claims)
+	assert.NoError(t, err)
+
+	// 刷新间隔内不会重新拉取
+	_, err = validator.Verify(rotated, nil)
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	backend := validator.(ValidatorImpl).tokenValidator.TokenBackends[0].(*JWKSTokenBackend)
+	backend.MinRefreshInterval = 0
+
+	_, err = validator.Verify(rotated, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	_, err = validator.Verify(token, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+}
+
+func TestKeyring_JWKSUniqueKID(t *testing.T) {
+	rsaPub, err := ParseRSAPublicKeyFromPEM(rsaKeyPair1[1])
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	// 同一个 kid 发布两个 JWK 时验签方无法选择公钥
+	_, err = NewKeyring([]*Key{{ID: "key-1", PublicKey: rsaPub, ECPrivateKey: ecKey}}, "")
+	assert.True(t, errors.Is(err, ErrMixedKeyTypes))
+
+	keyring, err := NewKeyring([]*Key{{ID: "key-1", PublicKey: rsaPub}, {ID: "key-2", ECPrivateKey: ecKey}}, "")
+	assert.NoError(t, err)
+	assert.Len(t, keyring.JWKS().Keys, 2)
+}
+
+func TestAuthenticator_JWKSHandler(t *testing.T) {
+	auth, err := NewAuthenticator(Conf{
+		Keys: []KeyConf{{ID: "key-1", RSAPrivateKey: rsaKeyPair1[0]}},
+	})
+	assert.NoError(t, err)
+	defer auth.Close()
+
+	handler := auth.JWKSHandler()
+	kids := func() []string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var set JSONWebKeySet
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &set))
+		ids := make([]string, 0, len(set.Keys))
+		for _, k := range set.Keys {
+			ids = append(ids, k.Kid)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"key-1"}, kids())
+
+	// 重新加载密钥后发布新的公钥
+	assert.NoError(t, auth.reload(Conf{
+		ActiveKeyID: "key-2",
+		Keys: []KeyConf{
+			{ID: "key-1", RSAPublicKey: rsaKeyPair1[1]},
+			{ID: "key-2", RSAPrivateKey: rsaKeyPair2[0]},
+		},
+	}))
+	assert.Equal(t, []string{"key-1", "key-2"}, kids())
+
+	rec := httptest.NewRecorder()
+	(&Authenticator{}).JWKSHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestJWKSTokenBackend_RefreshDoesNotBlockLookup(t *testing.T) {
+	h, err := NewJWKSHandlerWithConf(Conf{Keys: []KeyConf{{ID: "key-1", RSAPrivateKey: testJWKSKey[0]}}})
+	assert.NoError(t, err)
+
+	var requests int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) > 1 {
+			<-release
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	backend, err := NewJWKSTokenBackend(server.URL)
+	assert.NoError(t, err)
+	backend.MinRefreshInterval = 0
+	assert.NoError(t, backend.refresh())
+
+	done := make(chan error)
+	go func() { done <- backend.refresh() }()
+	for atomic.LoadInt32(&requests) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 拉取过程中依然可以查找缓存中的公钥
+	_, found, _ := backend.lookup("key-1")
+	assert.True(t, found)
+
+	close(release)
+	assert.NoError(t, <-done)
+}
+
+func TestJWKSTokenBackend_BodyLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"keys":[` + strings.Repeat(" ", maxJWKSBodySize) + `]}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewJWKSTokenBackend(server.URL)
+	assert.NoError(t, err)
+	_, err = backend.fetch()
+	assert.Error(t, err)
+}
+
+func TestJWKSTokenBackend_KeyPolicy(t *testing.T) {
+	pub, err := ParseRSAPublicKeyFromPEM(testJWKSKey[1])
+	assert.NoError(t, err)
+	smallPub, err := ParseRSAPublicKeyFromPEM(rsaKeyPair1[1])
+	assert.NoError(t, err)
+
+	rs256 := newRSAJSONWebKey("rs256", pub)
+	rs256.Alg = string(SigningMethodRS256)
+	mismatched := newRSAJSONWebKey("mismatched", pub)
+	mismatched.Alg = string(SigningMethodES256)
+
+	var set atomic.Value
+	set.Store(JSONWebKeySet{Keys: []JSONWebKey{rs256, mismatched, newRSAJSONWebKey("small", smallPub), newRSAJSONWebKey("any", pub)}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(set.Load())
+	}))
+	defer server.Close()
+
+	backend, err := NewJWKSTokenBackend(server.URL)
+	assert.NoError(t, err)
+	keys, err := backend.fetch()
+	assert.NoError(t, err)
+	// 短于 2048 位的 RSA 公钥和 alg 与密钥类型不一致的公钥会被忽略
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, "rs256")
+	assert.Contains(t, keys, "any")
+
+	token := func(method jwtlib.SigningMethod, kid string) *jwtlib.Token {
+		return &jwtlib.Token{Method: method, Header: map[string]interface{}{"alg": method.Alg(), "kid": kid}}
+	}
+	_, err = backend.ProvideKey(token(jwtlib.SigningMethodRS256, "rs256"))
+	assert.NoError(t, err)
+	// JWK 声明了 alg 时只接受该算法
+	_, err = backend.ProvideKey(token(jwtlib.SigningMethodRS512, "rs256"))
+	assert.True(t, errors.Is(err, ErrUnexpectedSigningMethod), "%v", err)
+	_, err = backend.ProvideKey(token(jwtlib.SigningMethodRS512, "any"))
+	assert.NoError(t, err)
+
+	// 重复的 kid 返回错误, 不会使用其中任意一个
+	set.Store(JSONWebKeySet{Keys: []JSONWebKey{rs256, rs256}})
+	_, err = backend.fetch()
+	assert.True(t, errors.Is(err, ErrDuplicateKeyID), "%v", err)
+}
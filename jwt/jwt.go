@@ -3,45 +3,94 @@
 package jwt
 
 import (
-	"crypto/rsa"
+	"context"
 	"errors"
 	"sync"
+	"time"
 
 	jwtlib "github.com/dgrijalva/jwt-go"
-	goa "goa.design/goa/v3/pkg"
+	"github.com/google/uuid"
 	"goa.design/goa/v3/security"
 )
 
 // warn(joe@2019/11/19): 这里的默认配置被设计为只能用于资源接口请求认证, 用作其他用途可能带来未知的安全风险
 type jwtOption struct {
-	hmacSecret    []byte
-	rsaPrivateKey *rsa.PrivateKey
-	rsaPublicKey  *rsa.PublicKey
+	keyring *Keyring
+	jwksURL string
 
-	tokenIssuer string
+	signingMethod  SigningMethod
+	allowedMethods []string
+
+	tokenIssuer   string
+	tokenLifetime time.Duration
+
+	expectedIssuers        []string
+	expectedAudiences      []string
+	leeway                 time.Duration
+	allowMissingExpiration bool
+
+	revoker Revoker
+
+	disableTokenCache bool
+	tokenCacheSize    int
+
+	accessList       AccessList
+	strictRoles      bool
+	accessListDryRun bool
+
+	// Authenticator 中所有 Validator 共用的 TokenValidator, 第一次验签时创建
+	mu        sync.Mutex
+	validator *TokenValidator
 }
 
 const (
 	defaultTokenIssuer = "authority"
+	// 默认 token 有效期, 单位秒
+	defaultTokenLifetime = 900
 )
 
 type SigningMethod string
 
 const (
 	SigningMethodRS256 SigningMethod = "RS256"
+	SigningMethodRS384 SigningMethod = "RS384"
 	SigningMethodRS512 SigningMethod = "RS512"
+	SigningMethodHS256 SigningMethod = "HS256"
+	SigningMethodHS384 SigningMethod = "HS384"
 	SigningMethodHS512 SigningMethod = "HS512"
+	SigningMethodES256 SigningMethod = "ES256"
+	SigningMethodES384 SigningMethod = "ES384"
+	SigningMethodES512 SigningMethod = "ES512"
+	SigningMethodEdDSA SigningMethod = "EdDSA"
 )
 
 func (sm SigningMethod) getSigningMethod() jwtlib.SigningMethod {
 	return jwtlib.GetSigningMethod(string(sm))
 }
 
+// family 返回签名算法所属的密钥类型: RS, ES, EdDSA, HS, 不支持的算法返回空字符串
+func (sm SigningMethod) family() string {
+	switch sm {
+	case SigningMethodRS256, SigningMethodRS384, SigningMethodRS512:
+		return "RS"
+	case SigningMethodES256, SigningMethodES384, SigningMethodES512:
+		return "ES"
+	case SigningMethodEdDSA:
+		return "EdDSA"
+	case SigningMethodHS256, SigningMethodHS384, SigningMethodHS512:
+		return "HS"
+	default:
+		return ""
+	}
+}
+
 var (
-	sharedOption *jwtOption
+	// sharedAuthenticator Init/SetupWithConf 初始化的默认 Authenticator, NewSignerImpl/NewValidatorImpl 以及包级别的函数都使用它
+	sharedAuthenticator = &Authenticator{}
 
 	ErrJwtSecretNotConfig = errors.New("jwt secret not config")
-	ErrNotSupportedClaims = errors.New("only support userClaims")
+	ErrNotSupportedClaims = errors.New("only support userClaims and claims embedding UserClaims")
+	ErrJwtNotInitialized  = errors.New("jwt not initialized")
 )
 
 // 使用默认配置初始化
@@ -50,11 +99,9 @@ func Init() error {
 }
 
 // 使用指定配置初始化
+// conf.KeyReloadInterval 大于 0 时启动 KeyWatcher, file:// 密钥文件变化后重新加载密钥
 func SetupWithConf(conf Conf) error {
-	var err error
-	sharedOption, err = newJwtOption(conf)
-
-	return err
+	return sharedAuthenticator.setup(conf)
 }
 
 func newAccessList() ([]*AccessListEntry, error) {
@@ -92,53 +139,170 @@ func (o *jwtOption) initWithConf(conf Conf) error {
 		return err
 	}
 
-	if conf.Secret != "" {
-		o.setHmacSecret(conf.Secret)
+	keys := make([]*Key, 0, len(conf.Keys)+1)
+	if legacy := conf.legacyKey(); !legacy.isEmpty() {
+		key, err := newKeyFromConf(legacy)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
 	}
 
-	if conf.RSAPrivateKey != "" {
-		if err := o.setRsaPrivateKey(conf.RSAPrivateKey); err != nil {
-			return ErrInvaliRSAPrivateKey.WithMessage(err.Error())
+	for _, kc := range conf.Keys {
+		key, err := newKeyFromConf(kc)
+		if err != nil {
+			return err
 		}
+		keys = append(keys, key)
 	}
 
-	if conf.RSAPublicKey != "" {
-		if err := o.setRSAPublicKey(conf.RSAPublicKey); err != nil {
-			return ErrInvalidRSAPublicKey.WithMessage(err.Error())
+	// 只配置了 JWKSURL 时没有本地密钥
+	if len(keys) > 0 {
+		keyring, err := NewKeyring(keys, conf.ActiveKeyID)
+		if err != nil {
+			return ErrInvalidKeyID.WithMessage(err.Error())
+		}
+		o.keyring = keyring
+	}
+	o.jwksURL = conf.JWKSURL
+
+	o.signingMethod = SigningMethod(conf.SigningMethod)
+	if o.signingMethod.family() == "ES" && o.keyring != nil {
+		// 曲线需要解析密钥后才能检查
+		if pub := o.keyring.Active().ecPublicKey(); pub != nil {
+			if method, _ := ecSigningMethod(pub.Curve); method != o.signingMethod {
+				return ErrInvalidSigningMethodConf.WithMessage(string(o.signingMethod) + " vs. " + string(method))
+			}
 		}
 	}
 
+	o.allowedMethods = conf.allowedSigningMethods()
+
+	o.expectedIssuers = conf.ExpectedIssuers
+	o.expectedAudiences = conf.ExpectedAudiences
+	o.leeway = time.Duration(conf.Leeway) * time.Second
+	o.allowMissingExpiration = conf.AllowMissingExpiration
+
+	revoker, err := newRevoker(conf.Revoker)
+	if err != nil {
+		return ErrInvalidRevokerConf.WithMessage(err.Error())
+	}
+	o.revoker = revoker
+
+	accessList, err := conf.accessList()
+	if err != nil {
+		return err
+	}
+	o.accessList = accessList
+	o.strictRoles = conf.StrictRoles
+	o.accessListDryRun = conf.AccessListDryRun
+
+	o.disableTokenCache = conf.DisableTokenCache
+	o.tokenCacheSize = conf.TokenCacheSize
+
 	if conf.TokenIssuer != "" {
 		o.tokenIssuer = conf.TokenIssuer
 	} else {
 		o.tokenIssuer = defaultTokenIssuer
 	}
 
+	if conf.TokenLifetime > 0 {
+		o.tokenLifetime = time.Duration(conf.TokenLifetime) * time.Second
+	} else {
+		o.tokenLifetime = defaultTokenLifetime * time.Second
+	}
+
 	return nil
 }
 
-func (o *jwtOption) setHmacSecret(secret string) {
-	o.hmacSecret = []byte(secret)
-}
+// sharedTokenValidator 返回 Authenticator 中所有 Validator 共用的 TokenValidator
+func (o *jwtOption) sharedTokenValidator() (*TokenValidator, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
 
-func (o *jwtOption) setRsaPrivateKey(key string) error {
-	priKey, err := ParseRSAPrivateKeyFromPEM(key)
-	if err != nil {
-		return err
+	if o.validator == nil {
+		validator, err := o.newTokenValidator()
+		if err != nil {
+			return nil, err
+		}
+		o.validator = validator
 	}
+	return o.validator, nil
+}
 
-	o.rsaPrivateKey = priKey
-	return nil
+// close 停止共用的 TokenValidator 的缓存清理, 正在使用的实例依然可以继续验签
+func (o *jwtOption) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.validator != nil {
+		_ = o.validator.Close()
+	}
 }
 
-func (o *jwtOption) setRSAPublicKey(key string) error {
-	pubKey, err := ParseRSAPublicKeyFromPEM(key)
-	if err != nil {
-		return err
+func newKeyFromConf(kc KeyConf) (*Key, error) {
+	key := &Key{ID: kc.ID}
+
+	if kc.Secret != "" {
+		secret, err := loadSecretSource(kc.Secret)
+		if err != nil {
+			return nil, ErrInvalidSecretConf.WithMessage(err.Error())
+		}
+		key.Secret = secret
 	}
 
-	o.rsaPublicKey = pubKey
-	return nil
+	if kc.RSAPrivateKey != "" {
+		priKey, err := parseRSAPrivateKey(kc.RSAPrivateKey, kc.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, ErrInvaliRSAPrivateKey.WithMessage(err.Error())
+		}
+		key.PrivateKey = priKey
+	}
+
+	if kc.RSAPublicKey != "" {
+		pubKey, err := ParseRSAPublicKeyFromPEM(kc.RSAPublicKey)
+		if err != nil {
+			return nil, ErrInvalidRSAPublicKey.WithMessage(err.Error())
+		}
+		key.PublicKey = pubKey
+	}
+
+	if kc.ECPrivateKey != "" {
+		priKey, err := parseECPrivateKey(kc.ECPrivateKey, kc.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, ErrInvalidECPrivateKey.WithMessage(err.Error())
+		}
+		if _, err := ecSigningMethod(priKey.Curve); err != nil {
+			return nil, ErrInvalidECPrivateKey.WithMessage(err.Error())
+		}
+		key.ECPrivateKey = priKey
+	}
+
+	if kc.ECPublicKey != "" {
+		pubKey, err := ParseECPublicKeyFromPEM(kc.ECPublicKey)
+		if err != nil {
+			return nil, ErrInvalidECPublicKey.WithMessage(err.Error())
+		}
+		key.ECPublicKey = pubKey
+	}
+
+	if kc.EdPrivateKey != "" {
+		priKey, err := parseEdPrivateKey(kc.EdPrivateKey, kc.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, ErrInvalidEdPrivateKey.WithMessage(err.Error())
+		}
+		key.EdPrivateKey = priKey
+	}
+
+	if kc.EdPublicKey != "" {
+		pubKey, err := ParseEdPublicKeyFromPEM(kc.EdPublicKey)
+		if err != nil {
+			return nil, ErrInvalidEdPublicKey.WithMessage(err.Error())
+		}
+		key.EdPublicKey = pubKey
+	}
+
+	return key, nil
 }
 
 // NewSigner 方便调用方可以 mock
@@ -157,19 +321,38 @@ type Signer interface {
 	Sign(claim jwtlib.Claims) (string, error)
 }
 
+// Issuer 可以直接签发登录 token 的 Signer, SignerImpl 实现了 Issuer
+// 使用 Signer 的地方通过类型断言判断是否支持 Issue
+type Issuer interface {
+	Signer
+	// Issue 签发 jwt 并填充 iss, iat, nbf, exp, jti 等标准声明, 返回 jwt 和过期时间
+	Issue(subject string, roles, scopes []string) (string, time.Time, error)
+}
+
 // Validator jwt validator interface
 type Validator interface {
 	// 验证 jwt 是否合法
 	Verify(tokenStr string, scheme *security.JWTScheme) (*UserClaims, error)
 }
 
+// ContextValidator 支持 context 和类型化错误的 Validator, ValidatorImpl 实现了 ContextValidator
+// 使用 Validator 的地方通过类型断言判断是否支持 VerifyContext, 不支持时使用 Verify
+type ContextValidator interface {
+	Validator
+	// 验证 jwt 是否合法, 返回的错误可以使用 errors.Is 判断错误类型
+	VerifyContext(ctx context.Context, tokenStr string) (*UserClaims, error)
+}
+
+// ClaimsValidator 支持自定义 claims 的 ContextValidator, ValidatorImpl 实现了 ClaimsValidator
+type ClaimsValidator interface {
+	ContextValidator
+	// 验证 jwt 是否合法, 并把 payload 解码到 claims, claims 为嵌入 UserClaims 的自定义结构体的指针
+	VerifyClaims(ctx context.Context, tokenStr string, claims interface{}) (*UserClaims, error)
+}
+
+// NewSignerImpl 使用 Init/SetupWithConf 初始化的配置签发, 没有初始化时返回 ErrJwtNotInitialized
 func NewSignerImpl() Signer {
-	if sharedOption == nil {
-		panic("jwt 未初始化")
-	}
-	return SignerImpl{
-		option: sharedOption,
-	}
+	return sharedAuthenticator.Signer()
 }
 
 func NewSignerImplWithConf(c Conf) (Signer, error) {
@@ -187,39 +370,72 @@ func NewSignerImplWithConf(c Conf) (Signer, error) {
 // warn(joe@2019/11/19): 默认实现的 Signer 设计用于登陆认证无法作其他用途, 用作其他用途可能带来未知的安全风险
 type SignerImpl struct {
 	option *jwtOption
+	// auth 不为 nil 时使用 auth 当前的配置, 重新加载密钥后使用新的密钥
+	auth *Authenticator
+}
+
+func (s SignerImpl) currentOption() *jwtOption {
+	if s.auth != nil {
+		return s.auth.currentOption()
+	}
+	return s.option
 }
 
+// Sign 签发 jwt, claim 可以是 UserClaims 或者嵌入 UserClaims 的自定义结构体
 func (s SignerImpl) Sign(claim jwtlib.Claims) (string, error) {
-	userClaims, ok := claim.(UserClaims)
-	if !ok {
+	if _, ok := claim.(CustomClaims); !ok {
 		return "", ErrNotSupportedClaims
 	}
 
-	// 优先使用 RSA 签名
-	if s.option.rsaPrivateKey != nil {
-		return userClaims.GetToken(SigningMethodRS512, s.option.rsaPrivateKey)
+	option := s.currentOption()
+	if option == nil {
+		return "", ErrJwtNotInitialized
 	}
+	if option.keyring == nil {
+		return "", ErrJwtSecretNotConfig
+	}
+	key := option.keyring.Active()
 
-	if s.option.hmacSecret != nil {
-		return userClaims.GetToken(SigningMethodHS512, s.option.hmacSecret)
+	method, secret := key.signingKey(option.signingMethod)
+	if secret == nil {
+		return "", ErrJwtSecretNotConfig
 	}
 
-	return "", ErrJwtSecretNotConfig
+	return signClaims(claim, method, key.ID, secret)
 }
 
-func NewValidatorImpl() Validator {
-	if sharedOption == nil {
-		panic("jwt 未初始化")
+func (s SignerImpl) Issue(subject string, roles, scopes []string) (string, time.Time, error) {
+	option := s.currentOption()
+	if option == nil {
+		return "", time.Time{}, ErrJwtNotInitialized
 	}
-	impl := ValidatorImpl{
-		option: sharedOption,
+
+	now := time.Now()
+	expiresAt := now.Add(option.tokenLifetime)
+
+	claims := UserClaims{
+		ID:        uuid.New().String(),
+		Subject:   subject,
+		Issuer:    option.tokenIssuer,
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+		Roles:     roles,
+		Scopes:    scopes,
 	}
 
-	if err := impl.setup(); err != nil {
-		panic("jwt 未初始化")
+	token, err := s.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
 	}
 
-	return impl
+	return token, time.Unix(claims.ExpiresAt, 0), nil
+}
+
+// NewValidatorImpl 使用 Init/SetupWithConf 初始化的配置验签, 所有实例共用一个 TokenValidator
+// 没有初始化时返回 ErrJwtNotInitialized
+func NewValidatorImpl() Validator {
+	return sharedAuthenticator.Validator()
 }
 
 func NewValidatorImplWithConf(c Conf) (Validator, error) {
@@ -228,15 +444,15 @@ func NewValidatorImplWithConf(c Conf) (Validator, error) {
 		return nil, err
 	}
 
-	impl := ValidatorImpl{
-		option: option,
-	}
-
-	if err := impl.setup(); err != nil {
+	tokenValidator, err := option.newTokenValidator()
+	if err != nil {
 		return nil, err
 	}
 
-	return impl, nil
+	return ValidatorImpl{
+		option:         option,
+		tokenValidator: tokenValidator,
+	}, nil
 }
 
 // ValidatorImpl warn(joe@2019/11/19): 这个 validator 只能用来验证由 `SingerImpl` 签发的 jwt
@@ -244,44 +460,106 @@ type ValidatorImpl struct {
 	option *jwtOption
 
 	tokenValidator *TokenValidator
+	// auth 不为 nil 时使用 auth 当前的 TokenValidator, 重新加载密钥后使用新的密钥
+	auth *Authenticator
 }
 
-func (v *ValidatorImpl) setup() error {
-	v.tokenValidator = NewTokenValidator()
-
-	v.tokenValidator.TokenSecret = string(v.option.hmacSecret)
-	v.tokenValidator.PrivateKey = v.option.rsaPrivateKey
-	v.tokenValidator.PublicKey = v.option.rsaPublicKey
-	v.tokenValidator.TokenIssuer = v.option.tokenIssuer
+func (o *jwtOption) newTokenValidator() (*TokenValidator, error) {
+	v := NewTokenValidator()
+
+	v.Keyring = o.keyring
+	v.JWKSURL = o.jwksURL
+	v.TokenSigningMethod = string(o.signingMethod)
+	v.AllowedSigningMethods = o.allowedMethods
+	v.ExpectedIssuers = o.expectedIssuers
+	v.ExpectedAudiences = o.expectedAudiences
+	v.Leeway = o.leeway
+	v.AllowMissingExpiration = o.allowMissingExpiration
+	v.Revoker = o.revoker
+	if o.disableTokenCache {
+		_ = v.Cache.Close()
+		v.Cache = nil
+	} else if o.tokenCacheSize > 0 {
+		v.Cache.MaxEntries = o.tokenCacheSize
+	}
+	v.TokenIssuer = o.tokenIssuer
 
-	v.tokenValidator.AccessList, _ = newAccessList()
+	v.AccessList = o.accessList
+	v.StrictRoles = o.strictRoles
+	v.AccessListDryRun = o.accessListDryRun
 
-	if err := v.tokenValidator.ConfigureTokenBackends(); err != nil {
-		return ErrInvalidBackendConfiguration.WithArgs("jwt", err)
+	if err := v.ConfigureTokenBackends(); err != nil {
+		_ = v.Close()
+		return nil, ErrInvalidBackendConfiguration.WithArgs("jwt", err)
 	}
 
-	return nil
+	return v, nil
+}
+
+// validator 返回验签使用的 TokenValidator
+func (v ValidatorImpl) validator() (*TokenValidator, error) {
+	if v.auth != nil {
+		return v.auth.tokenValidator()
+	}
+	if v.tokenValidator == nil {
+		return nil, ErrJwtNotInitialized
+	}
+	return v.tokenValidator, nil
 }
 
+// Verify 验证 jwt, 错误会转换为 goa 的 unauthorized 错误
+//
+// Deprecated: 使用 VerifyContext, 在 goa 服务中使用 goajwt.ErrorContext 转换错误
 func (v ValidatorImpl) Verify(token string, scheme *security.JWTScheme) (*UserClaims, error) {
-	userClaims, valid, err := v.tokenValidator.ValidateToken(token)
+	userClaims, err := v.VerifyContext(context.Background(), token)
+	if err != nil {
+		return nil, legacyGoaError(err)
+	}
+	return userClaims, nil
+}
+
+// VerifyContext 验证 jwt, 返回的错误为 *VerifyError, 可以使用 errors.Is 判断错误类型, 例如 ErrTokenExpired
+func (v ValidatorImpl) VerifyContext(ctx context.Context, token string) (*UserClaims, error) {
+	tokenValidator, err := v.validator()
 	if err != nil {
-		// nolint(errorlint): fixme
-		if e, ok := err.(*jwtlib.ValidationError); ok {
-			return nil, UnauthorizedErr(translateJwtValidationError(e))
-		}
 		return nil, err
 	}
 
+	userClaims, valid, err := tokenValidator.ValidateTokenContext(ctx, token)
+	if err != nil {
+		return nil, newVerifyError(err)
+	}
+
 	if !valid {
-		return nil, UnauthorizedErr("令牌错误")
+		return nil, &VerifyError{Kind: ErrTokenUnverifiable}
+	}
+
+	return userClaims, nil
+}
+
+// VerifyClaims 和 VerifyContext 相同, 验证通过后把 payload 解码到 claims
+func (v ValidatorImpl) VerifyClaims(ctx context.Context, token string, claims interface{}) (*UserClaims, error) {
+	userClaims, err := v.VerifyContext(ctx, token)
+	if err != nil {
+		return nil, err
 	}
 
+	if err := decodeClaims(token, claims); err != nil {
+		if errors.Is(err, ErrInvalidClaimsTarget) {
+			return nil, err
+		}
+		return nil, &VerifyError{Kind: ErrTokenMalformed, Err: err}
+	}
 	return userClaims, nil
 }
 
-func UnauthorizedErr(format string, args ...interface{}) error {
-	return goa.TemporaryError("unauthorized", format, args...)
+// RevokeToken 吊销 jti, 在 token 过期之前验证都会返回 ErrTokenRevoked
+func (v ValidatorImpl) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	tokenValidator, err := v.validator()
+	if err != nil {
+		return err
+	}
+	return tokenValidator.RevokeToken(ctx, jti, expiresAt)
 }
 
 var (
@@ -300,6 +578,49 @@ func ValidateToken(token string, scheme *security.JWTScheme) (*UserClaims, error
 	return validator.Verify(token, scheme)
 }
 
+// VerifyContext returns a UserClaims for JWT token, errors can be matched with errors.Is
+func VerifyContext(ctx context.Context, token string) (*UserClaims, error) {
+	validatorOnce.Do(func() {
+		validator = NewValidator()
+	})
+
+	return VerifyWith(ctx, validator, token)
+}
+
+// VerifyWith 使用 v 验证 jwt, v 实现了 ContextValidator 时使用 VerifyContext, 否则使用 Verify
+func VerifyWith(ctx context.Context, v Validator, token string) (*UserClaims, error) {
+	if cv, ok := v.(ContextValidator); ok {
+		return cv.VerifyContext(ctx, token)
+	}
+	return v.Verify(token, nil)
+}
+
+// VerifyClaims decodes JWT token into custom claims after verification
+func VerifyClaims(ctx context.Context, token string, claims interface{}) (*UserClaims, error) {
+	validatorOnce.Do(func() {
+		validator = NewValidator()
+	})
+
+	cv, ok := validator.(ClaimsValidator)
+	if !ok {
+		return nil, ErrNoClaimsValidator
+	}
+	return cv.VerifyClaims(ctx, token, claims)
+}
+
+// RevokeToken revokes a JWT token by jti
+func RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	validatorOnce.Do(func() {
+		validator = NewValidator()
+	})
+
+	impl, ok := validator.(ValidatorImpl)
+	if !ok {
+		return ErrNoRevoker
+	}
+	return impl.RevokeToken(ctx, jti, expiresAt)
+}
+
 // GetToken returns a signed JWT token
 func GetToken(claims jwtlib.Claims) (string, error) {
 	signerOnce.Do(func() {
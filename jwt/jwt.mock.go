@@ -5,10 +5,12 @@
 package jwt
 
 import (
+	context "context"
 	jwt "github.com/dgrijalva/jwt-go"
 	gomock "github.com/golang/mock/gomock"
 	security "goa.design/goa/v3/security"
 	reflect "reflect"
+	time "time"
 )
 
 // MockSigner is a mock of Signer interface
@@ -49,6 +51,60 @@ func (mr *MockSignerMockRecorder) Sign(claim interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockSigner)(nil).Sign), claim)
 }
 
+// MockIssuer is a mock of Issuer interface
+type MockIssuer struct {
+	ctrl     *gomock.Controller
+	recorder *MockIssuerMockRecorder
+}
+
+// MockIssuerMockRecorder is the mock recorder for MockIssuer
+type MockIssuerMockRecorder struct {
+	mock *MockIssuer
+}
+
+// NewMockIssuer creates a new mock instance
+func NewMockIssuer(ctrl *gomock.Controller) *MockIssuer {
+	mock := &MockIssuer{ctrl: ctrl}
+	mock.recorder = &MockIssuerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockIssuer) EXPECT() *MockIssuerMockRecorder {
+	return m.recorder
+}
+
+// Sign mocks base method
+func (m *MockIssuer) Sign(claim jwt.Claims) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", claim)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sign indicates an expected call of Sign
+func (mr *MockIssuerMockRecorder) Sign(claim interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockIssuer)(nil).Sign), claim)
+}
+
+// Issue mocks base method
+func (m *MockIssuer) Issue(subject string, roles, scopes []string) (string, time.Time, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issue", subject, roles, scopes)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(time.Time)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// Issue indicates an expected call of Issue
+func (mr *MockIssuerMockRecorder) Issue(subject, roles, scopes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issue", reflect.TypeOf((*MockIssuer)(nil).Issue), subject, roles, scopes)
+}
+
 // MockValidator is a mock of Validator interface
 type MockValidator struct {
 	ctrl     *gomock.Controller
@@ -86,3 +142,124 @@ func (mr *MockValidatorMockRecorder) Verify(tokenStr, scheme interface{}) *gomoc
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockValidator)(nil).Verify), tokenStr, scheme)
 }
+
+// MockContextValidator is a mock of ContextValidator interface
+type MockContextValidator struct {
+	ctrl     *gomock.Controller
+	recorder *MockContextValidatorMockRecorder
+}
+
+// MockContextValidatorMockRecorder is the mock recorder for MockContextValidator
+type MockContextValidatorMockRecorder struct {
+	mock *MockContextValidator
+}
+
+// NewMockContextValidator creates a new mock instance
+func NewMockContextValidator(ctrl *gomock.Controller) *MockContextValidator {
+	mock := &MockContextValidator{ctrl: ctrl}
+	mock.recorder = &MockContextValidatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockContextValidator) EXPECT() *MockContextValidatorMockRecorder {
+	return m.recorder
+}
+
+// Verify mocks base method
+func (m *MockContextValidator) Verify(tokenStr string, scheme *security.JWTScheme) (*UserClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", tokenStr, scheme)
+	ret0, _ := ret[0].(*UserClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify
+func (mr *MockContextValidatorMockRecorder) Verify(tokenStr, scheme interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockContextValidator)(nil).Verify), tokenStr, scheme)
+}
+
+// VerifyContext mocks base method
+func (m *MockContextValidator) VerifyContext(ctx context.Context, tokenStr string) (*UserClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyContext", ctx, tokenStr)
+	ret0, _ := ret[0].(*UserClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyContext indicates an expected call of VerifyContext
+func (mr *MockContextValidatorMockRecorder) VerifyContext(ctx, tokenStr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyContext", reflect.TypeOf((*MockContextValidator)(nil).VerifyContext), ctx, tokenStr)
+}
+
+// MockClaimsValidator is a mock of ClaimsValidator interface
+type MockClaimsValidator struct {
+	ctrl     *gomock.Controller
+	recorder *MockClaimsValidatorMockRecorder
+}
+
+// MockClaimsValidatorMockRecorder is the mock recorder for MockClaimsValidator
+type MockClaimsValidatorMockRecorder struct {
+	mock *MockClaimsValidator
+}
+
+// NewMockClaimsValidator creates a new mock instance
+func NewMockClaimsValidator(ctrl *gomock.Controller) *MockClaimsValidator {
+	mock := &MockClaimsValidator{ctrl: ctrl}
+	mock.recorder = &MockClaimsValidatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClaimsValidator) EXPECT() *MockClaimsValidatorMockRecorder {
+	return m.recorder
+}
+
+// Verify mocks base method
+func (m *MockClaimsValidator) Verify(tokenStr string, scheme *security.JWTScheme) (*UserClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", tokenStr, scheme)
+	ret0, _ := ret[0].(*UserClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify
+func (mr *MockClaimsValidatorMockRecorder) Verify(tokenStr, scheme interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockClaimsValidator)(nil).Verify), tokenStr, scheme)
+}
+
+// VerifyContext mocks base method
+func (m *MockClaimsValidator) VerifyContext(ctx context.Context, tokenStr string) (*UserClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyContext", ctx, tokenStr)
+	ret0, _ := ret[0].(*UserClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyContext indicates an expected call of VerifyContext
+func (mr *MockClaimsValidatorMockRecorder) VerifyContext(ctx, tokenStr interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyContext", reflect.TypeOf((*MockClaimsValidator)(nil).VerifyContext), ctx, tokenStr)
+}
+
+// VerifyClaims mocks base method
+func (m *MockClaimsValidator) VerifyClaims(ctx context.Context, tokenStr string, claims interface{}) (*UserClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "VerifyClaims", ctx, tokenStr, claims)
+	ret0, _ := ret[0].(*UserClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// VerifyClaims indicates an expected call of VerifyClaims
+func (mr *MockClaimsValidatorMockRecorder) VerifyClaims(ctx, tokenStr, claims interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "VerifyClaims", reflect.TypeOf((*MockClaimsValidator)(nil).VerifyClaims), ctx, tokenStr, claims)
+}
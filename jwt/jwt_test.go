@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"crypto/elliptic"
 	"testing"
 	"time"
 
@@ -60,14 +61,15 @@ ZWjW1nz5tWVGNTrv9wIDAQAB
 -----END PUBLIC KEY-----`,
 	}
 	rsaKeyPair3 = [2]string{
-		"tests/jwt.key",
-		"tests/jwt.key.pub",
+		"file://tests/jwt.key",
+		"file://tests/jwt.key.pub",
 	}
 )
 
 func TestSignerImpl_Sign(t *testing.T) {
 	_, err := NewSignerImplWithConf(Conf{})
 	assert.Equal(t, err, ErrNoRequiredSecret)
+	expiresAt := time.Now().Add(time.Hour).Unix()
 
 	cases := []struct {
 		caseName string
@@ -88,7 +90,7 @@ func TestSignerImpl_Sign(t *testing.T) {
 			"测试使用 RSA 签名",
 			Conf{RSAPrivateKey: rsaKeyPair1[0], RSAPublicKey: rsaKeyPair1[1]},
 			SigningMethodRS512,
-			UserClaims{ID: "123456"},
+			UserClaims{ID: "123456", ExpiresAt: expiresAt},
 			nil,
 		},
 
@@ -96,7 +98,7 @@ func TestSignerImpl_Sign(t *testing.T) {
 			"测试使用 HMAC 签名",
 			Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"},
 			SigningMethodHS512,
-			UserClaims{ID: "123456"},
+			UserClaims{ID: "123456", ExpiresAt: expiresAt},
 			nil,
 		},
 
@@ -104,7 +106,7 @@ func TestSignerImpl_Sign(t *testing.T) {
 			"测试使用 RSA 签名(通过文件)",
 			Conf{RSAPrivateKey: rsaKeyPair3[0], RSAPublicKey: rsaKeyPair3[1]},
 			SigningMethodRS256,
-			UserClaims{ID: "123456"},
+			UserClaims{ID: "123456", ExpiresAt: expiresAt},
 			nil,
 		},
 	}
@@ -192,13 +194,15 @@ func TestJwtValidatorImpl_Verify_RSA(t *testing.T) {
 	}
 
 	for idx, tc := range cases {
+		// 这些 token 签发时没有 exp
 		conf := Conf{
-			RSAPrivateKey: tc.keyPair[0],
-			RSAPublicKey:  tc.keyPair[1],
+			RSAPrivateKey:          tc.keyPair[0],
+			RSAPublicKey:           tc.keyPair[1],
+			AllowMissingExpiration: true,
 		}
 
-		var err error
-		sharedOption, err = newJwtOption(conf)
+		option, err := newJwtOption(conf)
+		sharedAuthenticator.storeOption(option)
 		assert.NoError(t, err)
 		if err != nil {
 			t.Logf("case %d failed", idx)
@@ -223,3 +227,123 @@ func TestJwtValidatorImpl_Verify_RSA(t *testing.T) {
 		assert.Equal(t, claims.ID, tc.claim.ID)
 	}
 }
+
+func TestSignerImpl_SigningMethod(t *testing.T) {
+	claims := UserClaims{ID: "123456", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	conf := Conf{RSAPrivateKey: rsaKeyPair1[0], SigningMethod: "RS256"}
+	signer, err := NewSignerImplWithConf(conf)
+	assert.NoError(t, err)
+	token, err := signer.Sign(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "RS256", tokenHeader(t, token)["alg"])
+
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	_, err = validator.Verify(token, nil)
+	assert.NoError(t, err)
+
+	// 默认签发的 RS512 不在允许列表中
+	legacyToken, err := claims.GetToken(SigningMethodRS512, mustParseRSAPrivateKey(t, rsaKeyPair1[0]))
+	assert.NoError(t, err)
+	_, err = validator.Verify(legacyToken, nil)
+	assert.Error(t, err)
+
+	// 显式配置允许列表后可以同时接受两种算法
+	conf.AllowedSigningMethods = []string{"RS256", "RS512"}
+	validator, err = NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	_, err = validator.Verify(legacyToken, nil)
+	assert.NoError(t, err)
+
+	// HS256 token 无法通过只允许 RS256 的验证
+	hsConf := Conf{
+		Secret:                "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM",
+		RSAPublicKey:          rsaKeyPair1[1],
+		SigningMethod:         "HS256",
+		AllowedSigningMethods: []string{"RS256"},
+	}
+	hsSigner, err := NewSignerImplWithConf(hsConf)
+	assert.NoError(t, err)
+	hsToken, err := hsSigner.Sign(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "HS256", tokenHeader(t, hsToken)["alg"])
+	validator, err = NewValidatorImplWithConf(hsConf)
+	assert.NoError(t, err)
+	_, err = validator.Verify(hsToken, nil)
+	assert.Error(t, err)
+}
+
+func TestConf_Validate_SigningMethod(t *testing.T) {
+	cases := []Conf{
+		{RSAPrivateKey: rsaKeyPair1[0], SigningMethod: "none"},
+		{RSAPrivateKey: rsaKeyPair1[0], SigningMethod: "HS256"},
+		{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM", SigningMethod: "RS256"},
+		{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM", AllowedSigningMethods: []string{"none"}},
+		{
+			ActiveKeyID:   "ec",
+			SigningMethod: "RS256",
+			Keys: []KeyConf{
+				{ID: "rsa", RSAPrivateKey: rsaKeyPair1[0]},
+				{ID: "ec", ECPrivateKey: newECKeyPairPEM(t, elliptic.P256())[0]},
+			},
+		},
+		{ECPrivateKey: newECKeyPairPEM(t, elliptic.P256())[0], SigningMethod: "ES384"},
+	}
+
+	for i, c := range cases {
+		_, err := newJwtOption(c)
+		// nolint(errorlint): fixme
+		e, ok := err.(ConfErr)
+		if assert.True(t, ok, "case %d: %v", i, err) {
+			assert.Equal(t, ConfErrCodeSigningMethod, e.Code(), "case %d", i)
+		}
+	}
+}
+
+func mustParseRSAPrivateKey(t *testing.T, key string) interface{} {
+	priKey, err := ParseRSAPrivateKeyFromPEM(key)
+	assert.NoError(t, err)
+	return priKey
+}
+
+func TestSignerImpl_Issue(t *testing.T) {
+	conf := Conf{RSAPrivateKey: rsaKeyPair1[0], TokenIssuer: "auth-service", TokenLifetime: 60}
+	signer, err := NewSignerImplWithConf(conf)
+	assert.NoError(t, err)
+
+	before := time.Now()
+	token, expiresAt, err := signer.(Issuer).Issue("user-1", []string{"guest"}, []string{"api:read"})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, before.Add(time.Minute), expiresAt, 2*time.Second)
+
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	claims, err := validator.Verify(token, nil)
+	assert.NoError(t, err)
+	if !assert.NotNil(t, claims) {
+		return
+	}
+
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "auth-service", claims.Issuer)
+	assert.Equal(t, []string{"guest"}, claims.Roles)
+	assert.Equal(t, []string{"api:read"}, claims.Scopes)
+	assert.Equal(t, expiresAt.Unix(), claims.ExpiresAt)
+	assert.Equal(t, claims.IssuedAt, claims.NotBefore)
+	assert.NotEmpty(t, claims.ID)
+
+	// 每次签发的 jti 都不同
+	token2, _, err := signer.(Issuer).Issue("user-1", nil, nil)
+	assert.NoError(t, err)
+	claims2, err := validator.Verify(token2, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.ID, claims2.ID)
+
+	// 默认有效期
+	signer, err = NewSignerImplWithConf(Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"})
+	assert.NoError(t, err)
+	_, expiresAt, err = signer.(Issuer).Issue("user-1", nil, nil)
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(defaultTokenLifetime*time.Second), expiresAt, 2*time.Second)
+}
@@ -0,0 +1,307 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+)
+
+// Keyring Errors
+const (
+	ErrEmptyKeyring     strError = "keyring has no keys"
+	ErrDuplicateKeyID   strError = "duplicate key id: %s"
+	ErrUnknownActiveKey strError = "active key id not found in keyring: %s"
+	ErrNoKeyMaterial    strError = "key %q has no key material"
+	ErrMixedKeyTypes    strError = "key %q has more than one public key type, use one kid per key type"
+	ErrUnsupportedCurve strError = "unsupported elliptic curve: %s"
+)
+
+// Key 一组签名/验签密钥, ID 会作为 jwt header 中的 kid
+// 旧配置(没有 kid)的密钥 ID 为空
+type Key struct {
+	ID           string
+	Secret       []byte
+	PrivateKey   *rsa.PrivateKey
+	PublicKey    *rsa.PublicKey
+	ECPrivateKey *ecdsa.PrivateKey
+	ECPublicKey  *ecdsa.PublicKey
+	EdPrivateKey ed25519.PrivateKey
+	EdPublicKey  ed25519.PublicKey
+	// Algorithm 验签时只接受这个签名算法, 为空时只检查算法和密钥类型是否一致
+	// 例如 JWKS 中声明了 alg 的公钥
+	Algorithm SigningMethod
+}
+
+// rsaPublicKey 返回用于验签的 RSA 公钥, 配置了私钥时优先从私钥导出
+func (k *Key) rsaPublicKey() *rsa.PublicKey {
+	if k.PrivateKey != nil {
+		return &k.PrivateKey.PublicKey
+	}
+	return k.PublicKey
+}
+
+// ecPublicKey 返回用于验签的 ECDSA 公钥, 配置了私钥时优先从私钥导出
+func (k *Key) ecPublicKey() *ecdsa.PublicKey {
+	if k.ECPrivateKey != nil {
+		return &k.ECPrivateKey.PublicKey
+	}
+	return k.ECPublicKey
+}
+
+// edPublicKey 返回用于验签的 Ed25519 公钥, 配置了私钥时优先从私钥导出
+func (k *Key) edPublicKey() ed25519.PublicKey {
+	if k.EdPrivateKey != nil {
+		pub, _ := k.EdPrivateKey.Public().(ed25519.PublicKey)
+		return pub
+	}
+	return k.EdPublicKey
+}
+
+func (k *Key) hasKeyMaterial() bool {
+	return len(k.Secret) > 0 || k.publicKeyTypes() > 0
+}
+
+// publicKeyTypes 返回 k 包含的公钥类型数量, JWKS 中一个 kid 只能对应一个公钥
+func (k *Key) publicKeyTypes() int {
+	n := 0
+	if k.rsaPublicKey() != nil {
+		n++
+	}
+	if k.ecPublicKey() != nil {
+		n++
+	}
+	if k.edPublicKey() != nil {
+		n++
+	}
+	return n
+}
+
+// verifyKey 根据 token 的签名算法返回对应的验签密钥
+func (k *Key) verifyKey(token *jwtlib.Token) (interface{}, error) {
+	if k.Algorithm != "" && token.Method.Alg() != string(k.Algorithm) {
+		return nil, ErrUnexpectedSigningMethod.WithArgs(k.Algorithm, token.Header["alg"])
+	}
+	switch token.Method.(type) {
+	case *jwtlib.SigningMethodRSA:
+		if pub := k.rsaPublicKey(); pub != nil {
+			return pub, nil
+		}
+	case *jwtlib.SigningMethodECDSA:
+		if pub := k.ecPublicKey(); pub != nil {
+			return pub, nil
+		}
+	case *signingMethodEdDSA:
+		if pub := k.edPublicKey(); pub != nil {
+			return pub, nil
+		}
+	case *jwtlib.SigningMethodHMAC:
+		if len(k.Secret) > 0 {
+			return k.Secret, nil
+		}
+	}
+	return nil, ErrUnexpectedSigningMethod.WithArgs(k.methodFamily(), token.Header["alg"])
+}
+
+func (k *Key) methodFamily() string {
+	switch {
+	case k.rsaPublicKey() != nil:
+		return "RS"
+	case k.ecPublicKey() != nil:
+		return "ES"
+	case k.edPublicKey() != nil:
+		return "EdDSA"
+	default:
+		return "HS"
+	}
+}
+
+// signingKey 返回签发使用的算法和私钥
+// method 为空时按 RSA(RS512) > ECDSA > Ed25519 > HMAC(HS512) 的优先级选择
+// 没有可以签发的密钥时返回的私钥为 nil
+func (k *Key) signingKey(method SigningMethod) (SigningMethod, interface{}) {
+	switch method.family() {
+	case "RS":
+		return method, rsaSigningKey(k.PrivateKey)
+	case "ES":
+		return method, ecSigningKey(k.ECPrivateKey)
+	case "EdDSA":
+		return method, edSigningKey(k.EdPrivateKey)
+	case "HS":
+		return method, hmacSigningKey(k.Secret)
+	}
+
+	switch {
+	case k.PrivateKey != nil:
+		return SigningMethodRS512, k.PrivateKey
+	case k.ECPrivateKey != nil:
+		method, _ := ecSigningMethod(k.ECPrivateKey.Curve)
+		return method, k.ECPrivateKey
+	case k.EdPrivateKey != nil:
+		return SigningMethodEdDSA, k.EdPrivateKey
+	case len(k.Secret) > 0:
+		return SigningMethodHS512, k.Secret
+	default:
+		return "", nil
+	}
+}
+
+// 以下函数避免把 nil 指针包装成非 nil 的 interface{}
+func rsaSigningKey(k *rsa.PrivateKey) interface{} {
+	if k == nil {
+		return nil
+	}
+	return k
+}
+
+func ecSigningKey(k *ecdsa.PrivateKey) interface{} {
+	if k == nil {
+		return nil
+	}
+	return k
+}
+
+func edSigningKey(k ed25519.PrivateKey) interface{} {
+	if k == nil {
+		return nil
+	}
+	return k
+}
+
+func hmacSigningKey(k []byte) interface{} {
+	if len(k) == 0 {
+		return nil
+	}
+	return k
+}
+
+// ecSigningMethod 根据曲线返回对应的 ES 签名算法
+func ecSigningMethod(curve elliptic.Curve) (SigningMethod, error) {
+	switch curve.Params().BitSize {
+	case 256:
+		return SigningMethodES256, nil
+	case 384:
+		return SigningMethodES384, nil
+	case 521:
+		return SigningMethodES512, nil
+	default:
+		return "", ErrUnsupportedCurve.WithArgs(curve.Params().Name)
+	}
+}
+
+// Keyring 保存多组密钥, 签发使用 active 密钥, 验签按 kid 选择密钥
+// 通过新增密钥并切换 active 实现密钥轮换, 旧密钥签发的 token 在过期前依然可以验证
+type Keyring struct {
+	keys   []*Key
+	byID   map[string]*Key
+	active *Key
+}
+
+// NewKeyring returns Keyring instance, activeID 为空时使用第一个密钥签发
+func NewKeyring(keys []*Key, activeID string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, ErrEmptyKeyring
+	}
+
+	r := &Keyring{
+		keys: make([]*Key, 0, len(keys)),
+		byID: make(map[string]*Key, len(keys)),
+	}
+	for _, k := range keys {
+		if !k.hasKeyMaterial() {
+			return nil, ErrNoKeyMaterial.WithArgs(k.ID)
+		}
+		if k.publicKeyTypes() > 1 {
+			return nil, ErrMixedKeyTypes.WithArgs(k.ID)
+		}
+		if _, exists := r.byID[k.ID]; exists {
+			return nil, ErrDuplicateKeyID.WithArgs(k.ID)
+		}
+		r.byID[k.ID] = k
+		r.keys = append(r.keys, k)
+	}
+
+	if activeID == "" {
+		r.active = r.keys[0]
+		return r, nil
+	}
+
+	active, ok := r.byID[activeID]
+	if !ok {
+		return nil, ErrUnknownActiveKey.WithArgs(activeID)
+	}
+	r.active = active
+
+	return r, nil
+}
+
+// Active 返回用于签发的密钥
+func (r *Keyring) Active() *Key {
+	return r.active
+}
+
+// Lookup 按 kid 查找密钥
+func (r *Keyring) Lookup(kid string) (*Key, bool) {
+	k, ok := r.byID[kid]
+	return k, ok
+}
+
+// Keys 返回 keyring 中的全部密钥
+func (r *Keyring) Keys() []*Key {
+	return r.keys
+}
+
+// KeyringTokenBackend 按 jwt header 中的 kid 从 Keyring 选择验签密钥
+type KeyringTokenBackend struct {
+	keyring *Keyring
+}
+
+// NewKeyringTokenBackend returns KeyringTokenBackend instance.
+func NewKeyringTokenBackend(keyring *Keyring) (*KeyringTokenBackend, error) {
+	if keyring == nil || len(keyring.keys) == 0 {
+		return nil, ErrEmptyKeyring
+	}
+	for _, k := range keyring.keys {
+		if len(k.Secret) > 0 && len(k.Secret) < 16 {
+			return nil, ErrInvalidSecretLength
+		}
+	}
+	return &KeyringTokenBackend{keyring: keyring}, nil
+}
+
+// ProvideKey provides key material from KeyringTokenBackend.
+// 没有 kid 的 token 使用 ID 为空的密钥验签, 没有这样的密钥时使用 active 密钥
+func (b *KeyringTokenBackend) ProvideKey(token *jwtlib.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := b.keyring.Lookup(kid)
+	if !ok {
+		if kid != "" {
+			return nil, ErrUnexpectedKID
+		}
+		key = b.keyring.Active()
+	}
+
+	return key.verifyKey(token)
+}
+
+// signClaims 签发 jwt, kid 不为空时写入 header
+func signClaims(claims jwtlib.Claims, method SigningMethod, kid string, secret interface{}) (string, error) {
+	if secret == nil {
+		return "", ErrUnsupportedSecret
+	}
+
+	sm := method.getSigningMethod()
+	if sm == nil {
+		return "", ErrInvalidSigningMethod
+	}
+
+	token := jwtlib.NewWithClaims(sm, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+
+	return token.SignedString(secret)
+}
@@ -0,0 +1,125 @@
+package jwt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func tokenHeader(t *testing.T, token string) map[string]interface{} {
+	parsed, _, err := new(jwtlib.Parser).ParseUnverified(token, jwtlib.MapClaims{})
+	assert.NoError(t, err)
+	return parsed.Header
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	claims := UserClaims{ID: "123456", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	oldConf := Conf{
+		ActiveKeyID: "key-1",
+		Keys: []KeyConf{
+			{ID: "key-1", RSAPrivateKey: rsaKeyPair1[0]},
+		},
+	}
+	oldSigner, err := NewSignerImplWithConf(oldConf)
+	assert.NoError(t, err)
+	oldToken, err := oldSigner.Sign(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-1", tokenHeader(t, oldToken)["kid"])
+
+	// 轮换: 新密钥用于签发, 旧密钥只保留公钥用于验签
+	rotatedConf := Conf{
+		ActiveKeyID: "key-2",
+		Keys: []KeyConf{
+			{ID: "key-1", RSAPublicKey: rsaKeyPair1[1]},
+			{ID: "key-2", RSAPrivateKey: rsaKeyPair2[0]},
+		},
+	}
+	newSigner, err := NewSignerImplWithConf(rotatedConf)
+	assert.NoError(t, err)
+	newToken, err := newSigner.Sign(claims)
+	assert.NoError(t, err)
+	assert.Equal(t, "key-2", tokenHeader(t, newToken)["kid"])
+
+	validator, err := NewValidatorImplWithConf(rotatedConf)
+	assert.NoError(t, err)
+
+	for _, token := range []string{oldToken, newToken} {
+		verified, err := validator.Verify(token, nil)
+		assert.NoError(t, err)
+		if assert.NotNil(t, verified) {
+			assert.Equal(t, claims.ID, verified.ID)
+		}
+	}
+
+	// 旧 validator 不认识 key-2
+	oldValidator, err := NewValidatorImplWithConf(oldConf)
+	assert.NoError(t, err)
+	_, err = oldValidator.Verify(newToken, nil)
+	assert.Error(t, err)
+}
+
+func TestKeyringTokenBackend_ProvideKey(t *testing.T) {
+	priKey, err := ParseRSAPrivateKeyFromPEM(rsaKeyPair1[0])
+	assert.NoError(t, err)
+
+	keyring, err := NewKeyring([]*Key{
+		{ID: "rsa", PrivateKey: priKey},
+		{ID: "hmac", Secret: []byte("ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM")},
+	}, "hmac")
+	assert.NoError(t, err)
+
+	backend, err := NewKeyringTokenBackend(keyring)
+	assert.NoError(t, err)
+
+	token := jwtlib.New(jwtlib.SigningMethodRS512)
+	token.Header["kid"] = "unknown"
+	_, err = backend.ProvideKey(token)
+	assert.True(t, errors.Is(err, ErrUnexpectedKID))
+
+	token.Header["kid"] = "rsa"
+	key, err := backend.ProvideKey(token)
+	assert.NoError(t, err)
+	assert.Equal(t, &priKey.PublicKey, key)
+
+	// kid 和签名算法不匹配
+	token.Header["kid"] = "hmac"
+	_, err = backend.ProvideKey(token)
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "signing method mismatch"))
+
+	// 没有 kid 时使用 active 密钥
+	delete(token.Header, "kid")
+	token.Method = jwtlib.SigningMethodHS512
+	key, err = backend.ProvideKey(token)
+	assert.NoError(t, err)
+	assert.Equal(t, keyring.Active().Secret, key)
+}
+
+func TestConf_Validate_Keys(t *testing.T) {
+	cases := []struct {
+		conf Conf
+		code int
+	}{
+		{Conf{Keys: []KeyConf{{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"}}}, ConfErrCodeKeyID},
+		{Conf{Keys: []KeyConf{
+			{ID: "a", Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"},
+			{ID: "a", Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"},
+		}}, ConfErrCodeKeyID},
+		{Conf{Keys: []KeyConf{{ID: "a"}}}, ConfErrCodeNoRequiredKey},
+		{Conf{ActiveKeyID: "b", Keys: []KeyConf{{ID: "a", Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"}}}, ConfErrCodeActiveKeyID},
+	}
+
+	for _, tc := range cases {
+		err := tc.conf.Validate()
+		// nolint(errorlint): fixme
+		e, ok := err.(ConfErr)
+		if assert.True(t, ok, "%v", err) {
+			assert.Equal(t, tc.code, e.Code())
+		}
+	}
+}
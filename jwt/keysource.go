@@ -0,0 +1,112 @@
+package jwt
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// 密钥来源前缀, 没有前缀时为直接配置的密钥内容
+//
+//	file:///etc/jwt/tls.key  从文件读取, 文件变化时 KeyWatcher 会重新加载
+//	env://JWT_PRIVATE_KEY    从环境变量读取
+const (
+	KeySourceFile = "file://"
+	KeySourceEnv  = "env://"
+)
+
+// Key Source Errors
+const (
+	ErrKeySourceFile   strError = "failed to read key file %s: %v"
+	ErrKeySourceEnv    strError = "key env %s is not set"
+	ErrImplicitKeyFile strError = "key %s is a bare file path, add the %s prefix to load it as a file: %s%s"
+	ErrEncryptedKey    strError = "private key is encrypted, passphrase is required"
+	ErrKeyNotEncrypted strError = "passphrase is set, but private key is not encrypted"
+	ErrKeyPassphrase   strError = "failed to decrypt private key, passphrase may be incorrect"
+)
+
+// LoadKeySource 按来源前缀读取密钥内容, 没有前缀时直接返回 source
+func LoadKeySource(source string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(source, KeySourceFile):
+		filename := strings.TrimPrefix(source, KeySourceFile)
+		content, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return nil, ErrKeySourceFile.WithArgs(filename, err)
+		}
+		return content, nil
+	case strings.HasPrefix(source, KeySourceEnv):
+		name := strings.TrimPrefix(source, KeySourceEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, ErrKeySourceEnv.WithArgs(name)
+		}
+		return []byte(value), nil
+	default:
+		return []byte(source), nil
+	}
+}
+
+// loadSecretSource 读取 HMAC secret 或者私钥口令, 去掉文件和环境变量末尾的换行
+func loadSecretSource(source string) ([]byte, error) {
+	content, err := LoadKeySource(source)
+	if err != nil {
+		return nil, err
+	}
+	if source != string(content) {
+		content = []byte(strings.TrimRight(string(content), "\r\n"))
+	}
+	return content, nil
+}
+
+// loadPEMSource 读取 PEM 格式的密钥
+// 以前没有前缀的配置会被当作文件路径尝试读取, 现在必须使用 file:// 前缀
+func loadPEMSource(source string) ([]byte, error) {
+	content, err := LoadKeySource(source)
+	if err != nil {
+		return nil, err
+	}
+	if source == string(content) && !strings.Contains(source, "-----BEGIN") && fileExists(source) {
+		return nil, ErrImplicitKeyFile.WithArgs(source, KeySourceFile, KeySourceFile, source)
+	}
+	return content, nil
+}
+
+// loadPrivateKeyPEM 读取 PEM 格式的私钥, 加密的 PKCS#8 私钥使用 passphrase 解密后返回未加密的 PKCS#8 PEM
+func loadPrivateKeyPEM(source, passphrase string) ([]byte, error) {
+	content, err := loadPEMSource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != pemTypeEncryptedPrivateKey {
+		if passphrase != "" {
+			return nil, ErrKeyNotEncrypted
+		}
+		return content, nil
+	}
+
+	if passphrase == "" {
+		return nil, ErrEncryptedKey
+	}
+	password, err := loadSecretSource(passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := decryptPKCS8PrivateKey(block.Bytes, password)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// keyFile 返回 file:// 来源的文件路径, 其他来源返回空字符串
+func keyFile(source string) string {
+	if strings.HasPrefix(source, KeySourceFile) {
+		return strings.TrimPrefix(source, KeySourceFile)
+	}
+	return ""
+}
@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testKeyPassphrase = "jwt-passphrase"
+
+func TestLoadKeySource(t *testing.T) {
+	_ = os.Setenv("JWT_TEST_SECRET", "secret-from-env\n")
+	defer os.Unsetenv("JWT_TEST_SECRET")
+
+	content, err := LoadKeySource("env://JWT_TEST_SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-from-env\n", string(content))
+
+	secret, err := loadSecretSource("env://JWT_TEST_SECRET")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-from-env", string(secret))
+
+	// 直接配置的 secret 不去掉换行
+	secret, err = loadSecretSource("inline\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "inline\n", string(secret))
+
+	_, err = LoadKeySource("env://JWT_TEST_NOT_SET")
+	assert.True(t, errors.Is(err, ErrKeySourceEnv), "%v", err)
+
+	_, err = LoadKeySource("file://tests/not-exists.key")
+	assert.True(t, errors.Is(err, ErrKeySourceFile), "%v", err)
+
+	content, err = LoadKeySource("file://tests/jwt.key.pub")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "-----BEGIN PUBLIC KEY-----")
+
+	// 没有 file:// 前缀的路径不再当作文件读取
+	_, err = ParseRSAPublicKeyFromPEM("tests/jwt.key.pub")
+	assert.True(t, errors.Is(err, ErrImplicitKeyFile), "%v", err)
+	assert.Contains(t, err.Error(), "add the file:// prefix")
+	assert.Contains(t, err.Error(), "file://tests/jwt.key.pub")
+
+	_, err = ParseRSAPublicKeyFromPEM(rsaKeyPair1[1])
+	assert.NoError(t, err)
+}
+
+func TestConf_KeySources(t *testing.T) {
+	_ = os.Setenv("JWT_TEST_PRIVATE_KEY", rsaKeyPair1[0])
+	_ = os.Setenv("JWT_TEST_PASSPHRASE", testKeyPassphrase)
+	defer os.Unsetenv("JWT_TEST_PRIVATE_KEY")
+	defer os.Unsetenv("JWT_TEST_PASSPHRASE")
+
+	claims := UserClaims{ID: "123456", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	cases := []struct {
+		caseName string
+		conf     Conf
+		err      error
+	}{
+		{
+			"从环境变量读取私钥",
+			Conf{RSAPrivateKey: "env://JWT_TEST_PRIVATE_KEY"},
+			nil,
+		},
+		{
+			"加密的 RSA 私钥",
+			Conf{RSAPrivateKey: "file://tests/jwt.enc.key", PrivateKeyPassphrase: testKeyPassphrase},
+			nil,
+		},
+		{
+			"从环境变量读取私钥口令",
+			Conf{RSAPrivateKey: "file://tests/jwt.enc.key", PrivateKeyPassphrase: "env://JWT_TEST_PASSPHRASE"},
+			nil,
+		},
+		{
+			"加密的 ECDSA 私钥",
+			Conf{Keys: []KeyConf{{ID: "ec", ECPrivateKey: "file://tests/ec.enc.key", PrivateKeyPassphrase: testKeyPassphrase}}},
+			nil,
+		},
+		{
+			"加密的 Ed25519 私钥",
+			Conf{Keys: []KeyConf{{ID: "ed", EdPrivateKey: "file://tests/ed.enc.key", PrivateKeyPassphrase: testKeyPassphrase}}},
+			nil,
+		},
+		{
+			"没有配置口令",
+			Conf{RSAPrivateKey: "file://tests/jwt.enc.key"},
+			ErrEncryptedKey,
+		},
+		{
+			"口令错误",
+			Conf{RSAPrivateKey: "file://tests/jwt.enc.key", PrivateKeyPassphrase: "wrong"},
+			ErrKeyPassphrase,
+		},
+		{
+			"私钥没有加密",
+			Conf{RSAPrivateKey: rsaKeyPair1[0], PrivateKeyPassphrase: testKeyPassphrase},
+			ErrInvaliRSAPrivateKey,
+		},
+		{
+			"secret 环境变量不存在",
+			Conf{Secret: "env://JWT_TEST_NOT_SET"},
+			ErrInvalidSecretConf,
+		},
+	}
+
+	for _, tc := range cases {
+		signer, err := NewSignerImplWithConf(tc.conf)
+		if tc.err != nil {
+			assert.Error(t, err, tc.caseName)
+			if confErr, ok := tc.err.(ConfErr); ok {
+				assert.Equal(t, confErr.Code(), err.(ConfErr).Code(), tc.caseName)
+			} else {
+				assert.Contains(t, err.Error(), tc.err.Error(), tc.caseName)
+			}
+			continue
+		}
+		if !assert.NoError(t, err, tc.caseName) {
+			continue
+		}
+
+		token, err := signer.Sign(claims)
+		assert.NoError(t, err, tc.caseName)
+
+		validator, err := NewValidatorImplWithConf(tc.conf)
+		assert.NoError(t, err, tc.caseName)
+		_, err = validator.(ContextValidator).VerifyContext(context.Background(), token)
+		assert.NoError(t, err, tc.caseName)
+	}
+}
+
+func TestConf_KeyFiles(t *testing.T) {
+	conf := Conf{
+		Secret:               "inline",
+		RSAPrivateKey:        "file://a.key",
+		PrivateKeyPassphrase: "file://passphrase",
+		Keys: []KeyConf{
+			{ID: "key-1", ECPublicKey: "file://" + filepath.Join("keys", "ec.pub")},
+			{ID: "key-2", EdPublicKey: "env://ED_PUBLIC_KEY"},
+		},
+	}
+	assert.Equal(t, []string{"a.key", "passphrase", "keys/ec.pub"}, conf.keyFiles())
+}
@@ -0,0 +1,115 @@
+package jwt
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// KeyWatcher 定期检查 file:// 密钥文件, 文件内容变化时调用 reload 重新加载密钥
+// Kubernetes 更新挂载的 secret 时替换的是目录中的符号链接, 所以比较文件内容而不是监听文件事件
+type KeyWatcher struct {
+	// Logger 记录重新加载的结果, 为 nil 时使用 zap.L()
+	Logger *zap.Logger
+
+	files    []string
+	interval time.Duration
+	reload   func() error
+
+	digest    [sha256.Size]byte
+	done      chan struct{}
+	stopped   chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+}
+
+// NewKeyWatcher returns KeyWatcher instance, 调用 Start 后开始检查
+// reload 返回错误时保留旧的密钥, 下次检查时重试
+func NewKeyWatcher(files []string, interval time.Duration, reload func() error) *KeyWatcher {
+	return &KeyWatcher{
+		files:    files,
+		interval: interval,
+		reload:   reload,
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+}
+
+// Start 记录当前的文件内容并启动检查 goroutine, 不再使用时需要调用 Close
+func (w *KeyWatcher) Start() {
+	w.startOnce.Do(func() {
+		w.digest = w.fileDigest()
+		go w.watch()
+	})
+}
+
+func (w *KeyWatcher) watch() {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			_, _ = w.check()
+		}
+	}
+}
+
+// check 文件内容变化时调用 reload, 返回是否重新加载成功
+func (w *KeyWatcher) check() (bool, error) {
+	digest := w.fileDigest()
+	if digest == w.digest {
+		return false, nil
+	}
+
+	if err := w.reload(); err != nil {
+		w.logger().Error("jwt keys reload failed", zap.Strings("files", w.files), zap.Error(err))
+		return false, err
+	}
+
+	w.digest = digest
+	w.logger().Info("jwt keys reloaded", zap.Strings("files", w.files))
+	return true, nil
+}
+
+// fileDigest 计算所有文件内容的摘要, 读取失败的文件使用错误信息参与计算
+func (w *KeyWatcher) fileDigest() [sha256.Size]byte {
+	h := sha256.New()
+	for _, f := range w.files {
+		content, err := ioutil.ReadFile(f)
+		if err != nil {
+			content = []byte(err.Error())
+		}
+		sum := sha256.Sum256(content)
+		_, _ = h.Write(sum[:])
+	}
+
+	var digest [sha256.Size]byte
+	copy(digest[:], h.Sum(nil))
+	return digest
+}
+
+// Close 停止检查 goroutine 并等待正在进行的重新加载完成, 可以重复调用
+func (w *KeyWatcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		// 没有调用 Start 时不会启动 goroutine, 同时阻止之后再启动
+		w.startOnce.Do(func() { close(w.stopped) })
+		<-w.stopped
+	})
+	return nil
+}
+
+func (w *KeyWatcher) logger() *zap.Logger {
+	if w.Logger != nil {
+		return w.Logger
+	}
+	return zap.L()
+}
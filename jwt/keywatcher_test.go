@@ -0,0 +1,117 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "secret")
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("v1"), 0600))
+
+	var reloads int
+	var reloadErr error
+	w := NewKeyWatcher([]string{filename}, time.Hour, func() error {
+		reloads++
+		return reloadErr
+	})
+	w.Start()
+	defer w.Close()
+
+	reloaded, err := w.check()
+	assert.False(t, reloaded)
+	assert.NoError(t, err)
+
+	// 重新加载失败时保留旧的摘要, 下次检查时重试
+	assert.NoError(t, ioutil.WriteFile(filename, []byte("v2"), 0600))
+	reloadErr = errors.New("half written")
+	reloaded, err = w.check()
+	assert.False(t, reloaded)
+	assert.Error(t, err)
+
+	reloadErr = nil
+	reloaded, err = w.check()
+	assert.True(t, reloaded)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reloads)
+
+	reloaded, _ = w.check()
+	assert.False(t, reloaded)
+
+	assert.NoError(t, w.Close())
+	assert.NoError(t, w.Close())
+
+	// 没有 Start 的 KeyWatcher 也可以 Close
+	assert.NoError(t, NewKeyWatcher(nil, time.Hour, nil).Close())
+}
+
+// writeSecretMount 模拟 Kubernetes 挂载 secret 的方式: secret -> ..data/secret, ..data -> 版本目录
+func writeSecretMount(t *testing.T, dir, version, secret string) {
+	versionDir := filepath.Join(dir, version)
+	assert.NoError(t, os.Mkdir(versionDir, 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(versionDir, "secret"), []byte(secret+"\n"), 0600))
+
+	tmpLink := filepath.Join(dir, "..data_tmp")
+	assert.NoError(t, os.Symlink(version, tmpLink))
+	assert.NoError(t, os.Rename(tmpLink, filepath.Join(dir, "..data")))
+
+	if _, err := os.Lstat(filepath.Join(dir, "secret")); os.IsNotExist(err) {
+		assert.NoError(t, os.Symlink(filepath.Join("..data", "secret"), filepath.Join(dir, "secret")))
+	}
+}
+
+func TestAuthenticator_KeyReload(t *testing.T) {
+	dir := t.TempDir()
+	writeSecretMount(t, dir, "v1", "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM")
+
+	conf := Conf{
+		Secret:            "file://" + filepath.Join(dir, "secret"),
+		KeyReloadInterval: 3600,
+		Revoker:           RevokerMemory,
+	}
+	auth, err := NewAuthenticator(conf)
+	assert.NoError(t, err)
+	defer auth.Close()
+
+	signer := auth.Signer()
+	validator := auth.Validator()
+	option := auth.currentOption()
+
+	oldToken, _, err := signer.Issue("alice", []string{"guest"}, nil)
+	assert.NoError(t, err)
+	_, err = validator.VerifyContext(context.Background(), oldToken)
+	assert.NoError(t, err)
+
+	writeSecretMount(t, dir, "v2", "0b5ee3bb-b9b5-4d0b-8cd8-b1a4ea0e4c2a")
+	reloaded, err := auth.watcher.check()
+	assert.True(t, reloaded)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, option, auth.currentOption())
+	assert.Equal(t, option.revoker, auth.currentOption().revoker)
+
+	// 已经创建的 signer 和 validator 使用新的密钥
+	_, err = validator.VerifyContext(context.Background(), oldToken)
+	assert.True(t, errors.Is(err, ErrTokenSignatureInvalid), "%v", err)
+
+	newToken, _, err := signer.Issue("alice", []string{"guest"}, nil)
+	assert.NoError(t, err)
+	_, err = validator.VerifyContext(context.Background(), newToken)
+	assert.NoError(t, err)
+
+	// 读取失败时保留当前的密钥
+	writeSecretMount(t, dir, "v3", "short")
+	reloaded, err = auth.watcher.check()
+	assert.False(t, reloaded)
+	assert.Error(t, err)
+	_, err = validator.VerifyContext(context.Background(), newToken)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,136 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// 内置的提示语言
+const (
+	LocaleZhCN = "zh-CN"
+	LocaleEn   = "en"
+
+	// DefaultLocale 没有指定语言或者 catalog 中没有对应语言时使用
+	DefaultLocale = LocaleZhCN
+)
+
+// ErrTokenInvalid 没有对应提示的错误类型统一使用的提示
+const ErrTokenInvalid strError = "invalid token"
+
+var defaultMessages = map[string]map[error]string{
+	LocaleZhCN: {
+		ErrTokenInvalid:          "令牌错误",
+		ErrTokenMalformed:        "令牌格式错误",
+		ErrTokenUnverifiable:     "令牌签名错误无法验证",
+		ErrTokenSignatureInvalid: "签名验证失败",
+		ErrTokenUnknownKID:       "令牌签名密钥不存在",
+		ErrTokenExpired:          "令牌已过期",
+		ErrTokenNotValidYet:      "令牌尚未生效",
+		ErrTokenIssuedAt:         "发布时间验证失败",
+		ErrTokenIssuer:           "申请人验证失败",
+		ErrTokenAudience:         "受众验证失败",
+		ErrTokenID:               "JTI 验证失败",
+		ErrTokenClaimsInvalid:    "通用声明验证错误",
+		ErrTokenRevoked:          "令牌已被吊销",
+		ErrAccessDenied:          "没有访问权限",
+	},
+	LocaleEn: {
+		ErrTokenInvalid:          "invalid token",
+		ErrTokenMalformed:        "token is malformed",
+		ErrTokenUnverifiable:     "token could not be verified",
+		ErrTokenSignatureInvalid: "token signature is invalid",
+		ErrTokenUnknownKID:       "token signing key is unknown",
+		ErrTokenExpired:          "token is expired",
+		ErrTokenNotValidYet:      "token is not valid yet",
+		ErrTokenIssuedAt:         "token used before issued",
+		ErrTokenIssuer:           "token issuer is not accepted",
+		ErrTokenAudience:         "token audience is not accepted",
+		ErrTokenID:               "token id is invalid",
+		ErrTokenClaimsInvalid:    "token claims are invalid",
+		ErrTokenRevoked:          "token has been revoked",
+		ErrAccessDenied:          "access denied",
+	},
+}
+
+// MessageCatalog 按错误类型和语言保存验证 jwt 失败时返回给用户的提示
+type MessageCatalog struct {
+	mu       sync.RWMutex
+	messages map[string]map[error]string
+}
+
+// NewMessageCatalog returns MessageCatalog instance with zh-CN and en messages.
+func NewMessageCatalog() *MessageCatalog {
+	c := &MessageCatalog{messages: map[string]map[error]string{}}
+	for locale, messages := range defaultMessages {
+		for kind, message := range messages {
+			c.Set(locale, kind, message)
+		}
+	}
+	return c
+}
+
+// Messages 默认的 MessageCatalog, goajwt 使用它生成提示
+var Messages = NewMessageCatalog()
+
+// Set 设置 locale 下错误类型 kind 的提示, kind 为 VerifyError.Kind, 例如 ErrTokenExpired
+func (c *MessageCatalog) Set(locale string, kind error, message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.messages[locale] == nil {
+		c.messages[locale] = map[error]string{}
+	}
+	c.messages[locale][kind] = message
+}
+
+// Message 返回 locale 下错误类型 kind 的提示
+// 依次查找 locale, locale 的主语言(例如 en-US 使用 en), DefaultLocale, 都没有时使用 ErrTokenInvalid 的提示
+func (c *MessageCatalog) Message(locale string, kind error) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	locales := []string{locale}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		locales = append(locales, locale[:i])
+	}
+	locales = append(locales, DefaultLocale)
+
+	for _, k := range []error{kind, ErrTokenInvalid} {
+		for _, l := range locales {
+			if message, ok := c.messages[l][k]; ok {
+				return message
+			}
+		}
+	}
+	return ErrTokenInvalid.Error()
+}
+
+// Translate 返回 err 的提示, err 可以是 VerifyContext 返回的错误或者 *jwtlib.ValidationError
+// 同时有多个错误位时使用最具体的错误类型
+func (c *MessageCatalog) Translate(locale string, err error) string {
+	// nolint(errorlint): newVerifyError 只返回 *VerifyError
+	verifyErr, ok := newVerifyError(err).(*VerifyError)
+	if !ok {
+		return c.Message(locale, ErrTokenInvalid)
+	}
+	return c.Message(locale, verifyErr.Kind)
+}
+
+type localeKey struct{}
+
+// WithLocale 在 context 中保存提示使用的语言, 例如在中间件中根据 Accept-Language 设置
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey{}, locale)
+}
+
+// LocaleFromContext 返回 WithLocale 保存的语言, 没有时返回 DefaultLocale
+func LocaleFromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey{}).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
+
+// LocaleResolver 从请求的 context 中选择提示使用的语言, 默认为 LocaleFromContext
+var LocaleResolver = LocaleFromContext
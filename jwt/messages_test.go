@@ -0,0 +1,98 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageCatalog_Translate(t *testing.T) {
+	catalog := NewMessageCatalog()
+
+	cases := []struct {
+		caseName string
+		locale   string
+		err      error
+		expected string
+	}{
+		{
+			"single flag",
+			LocaleZhCN,
+			jwtlib.NewValidationError("", jwtlib.ValidationErrorExpired),
+			"令牌已过期",
+		},
+		{
+			"most specific flag",
+			LocaleZhCN,
+			jwtlib.NewValidationError("", jwtlib.ValidationErrorExpired|jwtlib.ValidationErrorAudience),
+			"令牌已过期",
+		},
+		{
+			"signature before claims",
+			LocaleEn,
+			jwtlib.NewValidationError("", jwtlib.ValidationErrorSignatureInvalid|jwtlib.ValidationErrorExpired),
+			"token signature is invalid",
+		},
+		{
+			"unknown kid",
+			LocaleEn,
+			&jwtlib.ValidationError{Inner: ErrUnexpectedKID, Errors: jwtlib.ValidationErrorUnverifiable},
+			"token signing key is unknown",
+		},
+		{
+			"verify error",
+			LocaleEn,
+			&VerifyError{Kind: ErrAccessDenied, Err: ErrAccessNotAllowed},
+			"access denied",
+		},
+		{
+			"region falls back to language",
+			"en-US",
+			jwtlib.NewValidationError("", jwtlib.ValidationErrorNotValidYet),
+			"token is not valid yet",
+		},
+		{
+			"unknown locale falls back to default",
+			"fr",
+			jwtlib.NewValidationError("", jwtlib.ValidationErrorNotValidYet),
+			"令牌尚未生效",
+		},
+		{
+			"no flags",
+			LocaleEn,
+			jwtlib.NewValidationError("", 0),
+			"token could not be verified",
+		},
+		{
+			"nil",
+			LocaleEn,
+			nil,
+			"invalid token",
+		},
+		{
+			"typed nil validation error",
+			LocaleEn,
+			(*jwtlib.ValidationError)(nil),
+			"token could not be verified",
+		},
+	}
+
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, catalog.Translate(tc.locale, tc.err), tc.caseName)
+	}
+
+	assert.Equal(t, "令牌错误", translateJwtValidationError(nil))
+}
+
+func TestMessageCatalog_Set(t *testing.T) {
+	catalog := NewMessageCatalog()
+	catalog.Set("ja", ErrTokenExpired, "トークンの有効期限が切れています")
+
+	assert.Equal(t, "トークンの有効期限が切れています", catalog.Message("ja", ErrTokenExpired))
+	// 没有设置的错误类型使用 DefaultLocale
+	assert.Equal(t, "令牌已被吊销", catalog.Message("ja", ErrTokenRevoked))
+	// 未知的错误类型使用 ErrTokenInvalid 的提示
+	assert.Equal(t, "invalid token", catalog.Message(LocaleEn, errors.New("unknown")))
+}
@@ -0,0 +1,177 @@
+package jwt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1" // nolint(gosec): PBKDF2 默认的 PRF 为 hmacWithSHA1
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PKCS#8 Errors
+const (
+	ErrUnsupportedKeyCipher strError = "unsupported private key encryption: %s"
+	ErrInvalidEncryptedKey  strError = "invalid encrypted private key: %v"
+)
+
+const pemTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+
+// RFC 8018 中 PBES2 使用的算法
+var (
+	oidPBES2  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+
+	oidAES128CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8PrivateKey 解密 PBES2(PBKDF2 + AES-CBC) 加密的 PKCS#8 私钥, 返回未加密的 PKCS#8 DER
+// 例如 openssl pkcs8 -topk8 -v2 aes-256-cbc 生成的私钥
+func decryptPKCS8PrivateKey(der, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, ErrInvalidEncryptedKey.WithArgs(err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, ErrUnsupportedKeyCipher.WithArgs(info.Algorithm.Algorithm.String())
+	}
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, ErrInvalidEncryptedKey.WithArgs(err)
+	}
+
+	keyLen, err := aesKeyLength(params.EncryptionScheme.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, ErrInvalidEncryptedKey.WithArgs(err)
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, ErrInvalidEncryptedKey.WithArgs("invalid iv length")
+	}
+
+	key, err := deriveKey(params.KeyDerivationFunc, password, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	data := info.EncryptedData
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, ErrInvalidEncryptedKey.WithArgs("invalid encrypted data length")
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidEncryptedKey.WithArgs(err)
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, data)
+
+	plain, ok := unpadPKCS7(plain, aes.BlockSize)
+	if !ok {
+		return nil, ErrKeyPassphrase
+	}
+	// 口令错误时填充也可能恰好正确, 需要能解析为 PKCS#8 私钥
+	if _, err := x509.ParsePKCS8PrivateKey(plain); err != nil {
+		return nil, ErrKeyPassphrase
+	}
+	return plain, nil
+}
+
+func aesKeyLength(oid asn1.ObjectIdentifier) (int, error) {
+	switch {
+	case oid.Equal(oidAES128CBC):
+		return 16, nil
+	case oid.Equal(oidAES192CBC):
+		return 24, nil
+	case oid.Equal(oidAES256CBC):
+		return 32, nil
+	default:
+		return 0, ErrUnsupportedKeyCipher.WithArgs(oid.String())
+	}
+}
+
+func deriveKey(kdf pkix.AlgorithmIdentifier, password []byte, keyLen int) ([]byte, error) {
+	if !kdf.Algorithm.Equal(oidPBKDF2) {
+		return nil, ErrUnsupportedKeyCipher.WithArgs(kdf.Algorithm.String())
+	}
+
+	var params pbkdf2Params
+	if _, err := asn1.Unmarshal(kdf.Parameters.FullBytes, &params); err != nil {
+		return nil, ErrInvalidEncryptedKey.WithArgs(err)
+	}
+	if params.IterationCount <= 0 {
+		return nil, ErrInvalidEncryptedKey.WithArgs("invalid iteration count")
+	}
+	if params.KeyLength != 0 && params.KeyLength != keyLen {
+		return nil, ErrInvalidEncryptedKey.WithArgs("invalid key length")
+	}
+
+	var prf func() hash.Hash
+	switch oid := params.PRF.Algorithm; {
+	case len(oid) == 0, oid.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case oid.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case oid.Equal(oidHMACWithSHA384):
+		prf = sha512.New384
+	case oid.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, ErrUnsupportedKeyCipher.WithArgs(oid.String())
+	}
+
+	return pbkdf2.Key(password, params.Salt, params.IterationCount, keyLen, prf), nil
+}
+
+// unpadPKCS7 去掉 PKCS#7 填充, 固定检查最后一个块的所有字节, 耗时和填充内容无关
+func unpadPKCS7(data []byte, blockSize int) ([]byte, bool) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, false
+	}
+
+	n := data[len(data)-1]
+	good := subtle.ConstantTimeLessOrEq(1, int(n)) & subtle.ConstantTimeLessOrEq(int(n), blockSize)
+	for i := 1; i <= blockSize; i++ {
+		// 倒数 n 个字节都必须等于 n
+		inPadding := subtle.ConstantTimeLessOrEq(i, int(n))
+		good &= subtle.ConstantTimeSelect(inPadding, subtle.ConstantTimeByteEq(data[len(data)-i], n), 1)
+	}
+	if good != 1 {
+		return nil, false
+	}
+	return data[:len(data)-int(n)], true
+}
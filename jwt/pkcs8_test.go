@@ -0,0 +1,85 @@
+package jwt
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecryptPKCS8PrivateKey_OpenSSL(t *testing.T) {
+	plain, err := ioutil.ReadFile("tests/jwt.key")
+	assert.NoError(t, err)
+	expected, err := ParseRSAPrivateKeyFromPEM(string(plain))
+	assert.NoError(t, err)
+
+	// 使用 openssl 从 tests/jwt.key 生成, 口令为 testKeyPassphrase
+	cases := []struct {
+		file string
+		cmd  string
+	}{
+		{"tests/jwt.aes256.key", "openssl pkcs8 -topk8 -v2 aes-256-cbc"},
+		{"tests/jwt.aes128-sha1.key", "openssl pkcs8 -topk8 -v2 aes-128-cbc -v2prf hmacWithSHA1"},
+		{"tests/jwt.aes192-sha512.key", "openssl pkcs8 -topk8 -v2 aes-192-cbc -v2prf hmacWithSHA512 -iter 10000"},
+	}
+
+	for _, tc := range cases {
+		data, err := ioutil.ReadFile(tc.file)
+		assert.NoError(t, err, tc.cmd)
+		block, _ := pem.Decode(data)
+		if !assert.NotNil(t, block, tc.cmd) {
+			continue
+		}
+		assert.Equal(t, pemTypeEncryptedPrivateKey, block.Type, tc.cmd)
+
+		der, err := decryptPKCS8PrivateKey(block.Bytes, []byte(testKeyPassphrase))
+		if !assert.NoError(t, err, tc.cmd) {
+			continue
+		}
+		key, err := x509.ParsePKCS8PrivateKey(der)
+		assert.NoError(t, err, tc.cmd)
+		assert.Equal(t, expected, key, tc.cmd)
+
+		_, err = decryptPKCS8PrivateKey(block.Bytes, []byte("wrong"))
+		assert.Equal(t, ErrKeyPassphrase, err, tc.cmd)
+	}
+}
+
+func TestUnpadPKCS7(t *testing.T) {
+	block := func(tail ...byte) []byte {
+		b := make([]byte, 16-len(tail), 16)
+		for i := range b {
+			b[i] = 'a'
+		}
+		return append(b, tail...)
+	}
+	full := make([]byte, 16)
+	for i := range full {
+		full[i] = 16
+	}
+
+	cases := []struct {
+		caseName string
+		data     []byte
+		expected []byte
+		ok       bool
+	}{
+		{"one byte", block(1), block(1)[:15], true},
+		{"three bytes", block(3, 3, 3), block(3, 3, 3)[:13], true},
+		{"full block", append(block(1), full...), block(1), true},
+		{"zero", block(0), nil, false},
+		{"larger than block", block(17), nil, false},
+		{"inconsistent padding", block(2, 3, 3), nil, false},
+		{"first padding byte differs", append(block(1)[:1], full[1:]...), nil, false},
+		{"empty", nil, nil, false},
+		{"not block aligned", []byte{1}, nil, false},
+	}
+
+	for _, tc := range cases {
+		out, ok := unpadPKCS7(tc.data, 16)
+		assert.Equal(t, tc.ok, ok, tc.caseName)
+		assert.Equal(t, tc.expected, out, tc.caseName)
+	}
+}
@@ -0,0 +1,24 @@
+package jwt
+
+import (
+	"github.com/go-redis/redis/v7"
+
+	libsRedis "github.com/geeksmy/go-libs/redis"
+)
+
+// Redis Errors
+const (
+	ErrRedisNotConnected strError = "redis is not connected, call redis.Connect() first"
+)
+
+// redisClient 返回 client, 为 nil 时使用 redis.Client
+// 每次使用时查找, 在 redis.Connect() 之前创建的 store 连接后也可以使用
+func redisClient(client *redis.Client) (*redis.Client, error) {
+	if client != nil {
+		return client, nil
+	}
+	if libsRedis.Client == nil {
+		return nil, ErrRedisNotConnected
+	}
+	return libsRedis.Client, nil
+}
@@ -0,0 +1,278 @@
+package jwt
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Refresh Token Errors
+const (
+	ErrInvalidRefreshToken strError = "invalid refresh token"
+	ErrRefreshTokenExpired strError = "refresh token expired"
+	ErrRefreshTokenReused  strError = "refresh token reused, token family revoked"
+	ErrRefreshTokenRevoked strError = "refresh token family revoked"
+	ErrNoRefreshTokenStore strError = "no refresh token store"
+)
+
+const (
+	// 默认 refresh token 有效期, 单位秒
+	defaultRefreshTokenLifetime = 7 * 24 * 3600
+	refreshTokenBytes           = 32
+	// MemoryRefreshTokenStore 清理过期记录的间隔
+	refreshTokenCleanupInterval = time.Minute
+)
+
+// RefreshToken refresh token 在 store 中保存的记录, 不包含 token 明文
+// 同一次登录轮换出的 refresh token 属于同一个 family
+type RefreshToken struct {
+	ID        string   `json:"id"`
+	FamilyID  string   `json:"family_id"`
+	Subject   string   `json:"sub"`
+	Roles     []string `json:"roles,omitempty"`
+	Scopes    []string `json:"scopes,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// RefreshTokenStore 保存 refresh token 和 family 的状态
+type RefreshTokenStore interface {
+	// Save 保存新签发的 refresh token
+	Save(ctx context.Context, token RefreshToken) error
+	// Use 把 refresh token 标记为已使用并返回记录, 必须是原子操作
+	// token 不存在时返回 ErrInvalidRefreshToken, 已经使用过时返回记录和 ErrRefreshTokenReused
+	Use(ctx context.Context, id string) (*RefreshToken, error)
+	// RevokeFamily 吊销整个 family, 吊销状态至少保留到 until
+	RevokeFamily(ctx context.Context, familyID string, until time.Time) error
+	// IsFamilyRevoked 检查 family 是否已经被吊销
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
+}
+
+// TokenPair 一次签发的 access token 和 refresh token
+type TokenPair struct {
+	AccessToken           string
+	AccessTokenExpiresAt  time.Time
+	RefreshToken          string
+	RefreshTokenExpiresAt time.Time
+}
+
+// RefreshTokenManager 签发和轮换 refresh token
+// 每个 refresh token 只能使用一次, 重复使用会吊销整个 family
+type RefreshTokenManager struct {
+	Signer   Issuer
+	Store    RefreshTokenStore
+	Lifetime time.Duration
+}
+
+// NewRefreshTokenManager returns RefreshTokenManager instance.
+func NewRefreshTokenManager(signer Issuer, store RefreshTokenStore) *RefreshTokenManager {
+	return &RefreshTokenManager{
+		Signer:   signer,
+		Store:    store,
+		Lifetime: defaultRefreshTokenLifetime * time.Second,
+	}
+}
+
+// NewRefreshTokenManagerWithConf 使用指定配置创建 RefreshTokenManager
+func NewRefreshTokenManagerWithConf(c Conf, store RefreshTokenStore) (*RefreshTokenManager, error) {
+	if store == nil {
+		return nil, ErrNoRefreshTokenStore
+	}
+
+	option, err := newJwtOption(c)
+	if err != nil {
+		return nil, err
+	}
+
+	m := NewRefreshTokenManager(SignerImpl{option: option}, store)
+	if c.RefreshTokenLifetime > 0 {
+		m.Lifetime = time.Duration(c.RefreshTokenLifetime) * time.Second
+	}
+	return m, nil
+}
+
+// Issue 登录时签发 access token 和一个新 family 的 refresh token
+func (m *RefreshTokenManager) Issue(ctx context.Context, subject string, roles, scopes []string) (*TokenPair, error) {
+	return m.issue(ctx, uuid.New().String(), subject, roles, scopes)
+}
+
+// Refresh 使用 refresh token 换取新的 access token 和 refresh token
+func (m *RefreshTokenManager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	record, err := m.Store.Use(ctx, hashRefreshToken(refreshToken))
+	if errors.Is(err, ErrRefreshTokenReused) {
+		// 已使用的 token 再次出现说明 token 可能泄露, 吊销整个 family
+		if revokeErr := m.RevokeFamily(ctx, record.FamilyID); revokeErr != nil {
+			return nil, revokeErr
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := m.Store.IsFamilyRevoked(ctx, record.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrRefreshTokenRevoked
+	}
+
+	if record.ExpiresAt < time.Now().Unix() {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	return m.issue(ctx, record.FamilyID, record.Subject, record.Roles, record.Scopes)
+}
+
+// RevokeFamily 吊销 family 中所有的 refresh token, 例如用户退出登录
+func (m *RefreshTokenManager) RevokeFamily(ctx context.Context, familyID string) error {
+	// family 中最新的 refresh token 最晚在 Lifetime 之后过期
+	return m.Store.RevokeFamily(ctx, familyID, time.Now().Add(m.Lifetime))
+}
+
+func (m *RefreshTokenManager) issue(ctx context.Context, familyID, subject string, roles, scopes []string) (*TokenPair, error) {
+	accessToken, accessExpiresAt, err := m.Signer.Issue(subject, roles, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newRefreshTokenString()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExpiresAt := time.Now().Add(m.Lifetime)
+	record := RefreshToken{
+		ID:        hashRefreshToken(refreshToken),
+		FamilyID:  familyID,
+		Subject:   subject,
+		Roles:     roles,
+		Scopes:    scopes,
+		ExpiresAt: refreshExpiresAt.Unix(),
+	}
+	if err := m.Store.Save(ctx, record); err != nil {
+		return nil, err
+	}
+
+	pair := &TokenPair{
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: time.Unix(record.ExpiresAt, 0),
+	}
+	return pair, nil
+}
+
+func newRefreshTokenString() (string, error) {
+	b := make([]byte, refreshTokenBytes)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashRefreshToken store 中只保存 token 的摘要, store 泄露时 token 不会泄露
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MemoryRefreshTokenStore 基于内存的 RefreshTokenStore, 适用于单实例和测试
+// 过期的记录在 Save 时清理, 两次清理至少间隔 refreshTokenCleanupInterval
+type MemoryRefreshTokenStore struct {
+	mu          sync.Mutex
+	tokens      map[string]*memoryRefreshToken
+	families    map[string]time.Time
+	lastCleanup time.Time
+}
+
+type memoryRefreshToken struct {
+	token RefreshToken
+	used  bool
+}
+
+// NewMemoryRefreshTokenStore returns MemoryRefreshTokenStore instance.
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{
+		tokens:   map[string]*memoryRefreshToken{},
+		families: map[string]time.Time{},
+	}
+}
+
+// Save 保存 refresh token, 距离上次清理超过 refreshTokenCleanupInterval 时清理已过期的记录
+func (s *MemoryRefreshTokenStore) Save(_ context.Context, token RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now := time.Now(); now.Sub(s.lastCleanup) >= refreshTokenCleanupInterval {
+		s.cleanup(now)
+		s.lastCleanup = now
+	}
+	s.tokens[token.ID] = &memoryRefreshToken{token: token}
+	return nil
+}
+
+// Use 把 refresh token 标记为已使用
+func (s *MemoryRefreshTokenStore) Use(_ context.Context, id string) (*RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.tokens[id]
+	if !exists {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	token := entry.token
+	if entry.used {
+		return &token, ErrRefreshTokenReused
+	}
+	entry.used = true
+	return &token, nil
+}
+
+// RevokeFamily 吊销 family
+func (s *MemoryRefreshTokenStore) RevokeFamily(_ context.Context, familyID string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.families[familyID] = until
+	return nil
+}
+
+// IsFamilyRevoked 检查 family 是否已经被吊销
+func (s *MemoryRefreshTokenStore) IsFamilyRevoked(_ context.Context, familyID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, exists := s.families[familyID]
+	if !exists {
+		return false, nil
+	}
+	return time.Now().Before(until), nil
+}
+
+func (s *MemoryRefreshTokenStore) cleanup(now time.Time) {
+	ts := now.Unix()
+	for id, entry := range s.tokens {
+		if entry.token.ExpiresAt < ts {
+			delete(s.tokens, id)
+		}
+	}
+	for id, until := range s.families {
+		if now.After(until) {
+			delete(s.families, id)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const (
+	defaultRefreshTokenKeyPrefix = "jwt:refresh:"
+)
+
+// RedisRefreshTokenStore 基于 redis 的 RefreshTokenStore, 适用于多实例部署
+type RedisRefreshTokenStore struct {
+	client    *redis.Client
+	KeyPrefix string
+}
+
+// NewRedisRefreshTokenStore returns RedisRefreshTokenStore instance.
+// client 为 nil 时使用 redis.Client, 使用前需要先调用 redis.Connect(), 否则返回 ErrRedisNotConnected
+func NewRedisRefreshTokenStore(client *redis.Client) *RedisRefreshTokenStore {
+	return &RedisRefreshTokenStore{
+		client:    client,
+		KeyPrefix: defaultRefreshTokenKeyPrefix,
+	}
+}
+
+func (s *RedisRefreshTokenStore) getClient(ctx context.Context) (*redis.Client, error) {
+	client, err := redisClient(s.client)
+	if err != nil {
+		return nil, err
+	}
+	return client.WithContext(ctx), nil
+}
+
+func (s *RedisRefreshTokenStore) tokenKey(id string) string {
+	return s.KeyPrefix + "token:" + id
+}
+
+func (s *RedisRefreshTokenStore) usedKey(id string) string {
+	return s.KeyPrefix + "used:" + id
+}
+
+func (s *RedisRefreshTokenStore) familyKey(familyID string) string {
+	return s.KeyPrefix + "family:" + familyID
+}
+
+// Save 保存 refresh token, 过期后由 redis 自动删除
+func (s *RedisRefreshTokenStore) Save(ctx context.Context, token RefreshToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(time.Unix(token.ExpiresAt, 0))
+	if ttl <= 0 {
+		return ErrRefreshTokenExpired
+	}
+
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Set(s.tokenKey(token.ID), data, ttl).Err()
+}
+
+// Use 通过 SETNX 把 refresh token 标记为已使用
+func (s *RedisRefreshTokenStore) Use(ctx context.Context, id string) (*RefreshToken, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := client.Get(s.tokenKey(id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token := &RefreshToken{}
+	if err := json.Unmarshal(data, token); err != nil {
+		return nil, err
+	}
+
+	ttl := time.Until(time.Unix(token.ExpiresAt, 0))
+	if ttl <= 0 {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	first, err := client.SetNX(s.usedKey(id), 1, ttl).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		return token, ErrRefreshTokenReused
+	}
+
+	return token, nil
+}
+
+// RevokeFamily 吊销 family, 到期后由 redis 自动删除
+func (s *RedisRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Set(s.familyKey(familyID), 1, ttl).Err()
+}
+
+// IsFamilyRevoked 检查 family 是否已经被吊销
+func (s *RedisRefreshTokenStore) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	client, err := s.getClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := client.Exists(s.familyKey(familyID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
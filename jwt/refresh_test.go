@@ -0,0 +1,205 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+
+	libsRedis "github.com/geeksmy/go-libs/redis"
+)
+
+func testRefreshTokenManager(t *testing.T, store RefreshTokenStore) {
+	ctx := context.Background()
+	conf := Conf{RSAPrivateKey: rsaKeyPair1[0], RefreshTokenLifetime: 3600}
+
+	manager, err := NewRefreshTokenManagerWithConf(conf, store)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Hour, manager.Lifetime)
+
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+
+	pair, err := manager.Issue(ctx, "user-1", []string{"guest"}, []string{"api:read"})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, pair.RefreshToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), pair.RefreshTokenExpiresAt, 2*time.Second)
+
+	claims, err := validator.Verify(pair.AccessToken, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+
+	// refresh token 不能当作 access token 使用
+	_, err = validator.Verify(pair.RefreshToken, nil)
+	assert.Error(t, err)
+
+	rotated, err := manager.Refresh(ctx, pair.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, pair.RefreshToken, rotated.RefreshToken)
+
+	claims, err = validator.Verify(rotated.AccessToken, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, []string{"api:read"}, claims.Scopes)
+
+	// 重复使用已经轮换过的 refresh token 会吊销整个 family
+	_, err = manager.Refresh(ctx, pair.RefreshToken)
+	assert.True(t, errors.Is(err, ErrRefreshTokenReused), "%v", err)
+
+	_, err = manager.Refresh(ctx, rotated.RefreshToken)
+	assert.True(t, errors.Is(err, ErrRefreshTokenRevoked), "%v", err)
+
+	// 其他 family 不受影响
+	other, err := manager.Issue(ctx, "user-2", nil, nil)
+	assert.NoError(t, err)
+	_, err = manager.Refresh(ctx, other.RefreshToken)
+	assert.NoError(t, err)
+
+	_, err = manager.Refresh(ctx, "unknown")
+	assert.True(t, errors.Is(err, ErrInvalidRefreshToken), "%v", err)
+}
+
+func TestRefreshTokenManager_Memory(t *testing.T) {
+	testRefreshTokenManager(t, NewMemoryRefreshTokenStore())
+}
+
+func TestRefreshTokenManager_Expired(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRefreshTokenStore()
+	signer, err := NewSignerImplWithConf(Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"})
+	assert.NoError(t, err)
+
+	manager := NewRefreshTokenManager(signer.(Issuer), store)
+	manager.Lifetime = -time.Second
+
+	pair, err := manager.Issue(ctx, "user-1", nil, nil)
+	assert.NoError(t, err)
+
+	_, err = manager.Refresh(ctx, pair.RefreshToken)
+	assert.True(t, errors.Is(err, ErrRefreshTokenExpired), "%v", err)
+}
+
+func TestMemoryRefreshTokenStore_Cleanup(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryRefreshTokenStore()
+	expired := time.Now().Add(-time.Second).Unix()
+
+	// 第一次 Save 清理, 之后的 Save 在间隔内不会再遍历所有记录
+	for _, id := range []string{"t1", "t2", "t3"} {
+		assert.NoError(t, store.Save(ctx, RefreshToken{ID: id, ExpiresAt: expired}))
+	}
+	assert.Len(t, store.tokens, 3)
+
+	store.lastCleanup = time.Now().Add(-refreshTokenCleanupInterval)
+	assert.NoError(t, store.Save(ctx, RefreshToken{ID: "t4", ExpiresAt: time.Now().Add(time.Minute).Unix()}))
+	assert.Len(t, store.tokens, 1)
+	assert.Contains(t, store.tokens, "t4")
+}
+
+func TestRefreshTokenManager_Redis(t *testing.T) {
+	uri := os.Getenv("REDIS_URI")
+	if uri == "" {
+		t.Skip("REDIS_URI not set")
+	}
+
+	opts, err := redis.ParseURL(uri)
+	assert.NoError(t, err)
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	store := NewRedisRefreshTokenStore(client)
+	store.KeyPrefix = "jwt:refresh:test:" + time.Now().Format(time.RFC3339Nano) + ":"
+	testRefreshTokenManager(t, store)
+}
+
+func newMiniredisClient(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+func TestRefreshTokenManager_Miniredis(t *testing.T) {
+	mr, client := newMiniredisClient(t)
+	defer mr.Close()
+	defer client.Close()
+
+	testRefreshTokenManager(t, NewRedisRefreshTokenStore(client))
+}
+
+func TestRedisRefreshTokenStore(t *testing.T) {
+	ctx := context.Background()
+	mr, client := newMiniredisClient(t)
+	defer mr.Close()
+	defer client.Close()
+
+	store := NewRedisRefreshTokenStore(client)
+	token := RefreshToken{ID: "token-1", FamilyID: "family-1", Subject: "user-1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	assert.NoError(t, store.Save(ctx, token))
+	assert.True(t, mr.Exists("jwt:refresh:token:token-1"))
+	assert.InDelta(t, time.Hour.Seconds(), mr.TTL("jwt:refresh:token:token-1").Seconds(), 2)
+
+	record, err := store.Use(ctx, "token-1")
+	assert.NoError(t, err)
+	assert.Equal(t, &token, record)
+
+	// 第二次使用返回记录和 ErrRefreshTokenReused, 调用方据此吊销 family
+	record, err = store.Use(ctx, "token-1")
+	assert.True(t, errors.Is(err, ErrRefreshTokenReused), "%v", err)
+	if assert.NotNil(t, record) {
+		assert.Equal(t, "family-1", record.FamilyID)
+	}
+
+	_, err = store.Use(ctx, "unknown")
+	assert.True(t, errors.Is(err, ErrInvalidRefreshToken), "%v", err)
+
+	revoked, err := store.IsFamilyRevoked(ctx, "family-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.NoError(t, store.RevokeFamily(ctx, "family-1", time.Now().Add(time.Minute)))
+	revoked, err = store.IsFamilyRevoked(ctx, "family-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// 吊销状态到期后由 redis 删除
+	mr.FastForward(2 * time.Minute)
+	revoked, err = store.IsFamilyRevoked(ctx, "family-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.True(t, errors.Is(store.Save(ctx, RefreshToken{ID: "token-2", ExpiresAt: time.Now().Add(-time.Second).Unix()}), ErrRefreshTokenExpired))
+}
+
+func TestRedisRefreshTokenStore_NotConnected(t *testing.T) {
+	ctx := context.Background()
+	prev := libsRedis.Client
+	libsRedis.Client = nil
+	defer func() { libsRedis.Client = prev }()
+
+	// 在 redis.Connect() 之前创建
+	store := NewRedisRefreshTokenStore(nil)
+	token := RefreshToken{ID: "token-1", FamilyID: "family-1", ExpiresAt: time.Now().Add(time.Hour).Unix()}
+
+	assert.True(t, errors.Is(store.Save(ctx, token), ErrRedisNotConnected))
+	_, err := store.Use(ctx, "token-1")
+	assert.True(t, errors.Is(err, ErrRedisNotConnected))
+	assert.True(t, errors.Is(store.RevokeFamily(ctx, "family-1", time.Now().Add(time.Minute)), ErrRedisNotConnected))
+	_, err = store.IsFamilyRevoked(ctx, "family-1")
+	assert.True(t, errors.Is(err, ErrRedisNotConnected))
+
+	mr, client := newMiniredisClient(t)
+	defer mr.Close()
+	defer client.Close()
+	libsRedis.Client = client
+
+	assert.NoError(t, store.Save(ctx, token))
+	_, err = store.Use(ctx, "token-1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,83 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Revoker Errors
+const (
+	ErrTokenRevoked      strError = "token revoked"
+	ErrEmptyTokenID      strError = "token has no jti, can not be revoked"
+	ErrNoRevoker         strError = "no revoker configured"
+	ErrUnsupportedRevoke strError = "unsupported revoker: %s"
+)
+
+const (
+	RevokerMemory = "memory"
+	RevokerRedis  = "redis"
+)
+
+// Revoker 记录被吊销的 jti, 记录保留到 token 过期
+type Revoker interface {
+	// Revoke 吊销 jti, expiresAt 之后记录可以被删除, 没有 exp 的 token 传入零值表示永久保留
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// IsRevoked 检查 jti 是否已经被吊销
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// newRevoker 根据配置创建 Revoker, 为空时不启用吊销检查
+func newRevoker(name string) (Revoker, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case RevokerMemory:
+		return NewMemoryRevoker(), nil
+	case RevokerRedis:
+		return NewRedisRevoker(nil), nil
+	default:
+		return nil, ErrUnsupportedRevoke.WithArgs(name)
+	}
+}
+
+// MemoryRevoker 基于内存的 Revoker, 只对当前进程生效
+type MemoryRevoker struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+// NewMemoryRevoker returns MemoryRevoker instance.
+func NewMemoryRevoker() *MemoryRevoker {
+	return &MemoryRevoker{
+		entries: map[string]time.Time{},
+	}
+}
+
+// Revoke 吊销 jti, 同时清理已过期的记录
+func (r *MemoryRevoker) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return ErrEmptyTokenID
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for k, exp := range r.entries {
+		if !exp.IsZero() && now.After(exp) {
+			delete(r.entries, k)
+		}
+	}
+	r.entries[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked 检查 jti 是否已经被吊销
+func (r *MemoryRevoker) IsRevoked(_ context.Context, jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, exists := r.entries[jti]
+	return exists, nil
+}
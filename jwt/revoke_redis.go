@@ -0,0 +1,71 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+)
+
+const (
+	defaultRevokerKeyPrefix = "jwt:revoked:"
+)
+
+// RedisRevoker 基于 redis 的 Revoker, 多实例之间共享吊销记录
+type RedisRevoker struct {
+	client    *redis.Client
+	KeyPrefix string
+}
+
+// NewRedisRevoker returns RedisRevoker instance.
+// client 为 nil 时使用 redis.Client, 使用前需要先调用 redis.Connect(), 否则返回 ErrRedisNotConnected
+func NewRedisRevoker(client *redis.Client) *RedisRevoker {
+	return &RedisRevoker{
+		client:    client,
+		KeyPrefix: defaultRevokerKeyPrefix,
+	}
+}
+
+func (r *RedisRevoker) getClient(ctx context.Context) (*redis.Client, error) {
+	client, err := redisClient(r.client)
+	if err != nil {
+		return nil, err
+	}
+	return client.WithContext(ctx), nil
+}
+
+// Revoke 吊销 jti, 记录在 token 过期后由 redis 自动删除
+func (r *RedisRevoker) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return ErrEmptyTokenID
+	}
+
+	// 没有 exp 的 token 永久保留吊销记录
+	var ttl time.Duration
+	if !expiresAt.IsZero() {
+		ttl = time.Until(expiresAt)
+		if ttl <= 0 {
+			// token 已经过期, 无需记录
+			return nil
+		}
+	}
+
+	client, err := r.getClient(ctx)
+	if err != nil {
+		return err
+	}
+	return client.Set(r.KeyPrefix+jti, 1, ttl).Err()
+}
+
+// IsRevoked 检查 jti 是否已经被吊销
+func (r *RedisRevoker) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	client, err := r.getClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := client.Exists(r.KeyPrefix + jti).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,133 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+
+	libsRedis "github.com/geeksmy/go-libs/redis"
+)
+
+func testRevoker(t *testing.T, revoker Revoker) {
+	ctx := context.Background()
+	secret := "75f03764-147c-4d87-b2f0-4fda89e331c8"
+
+	v := NewTokenValidator()
+	v.TokenSecret = secret
+	v.AccessList, _ = newAccessList()
+	v.Revoker = revoker
+	assert.NoError(t, v.ConfigureTokenBackends())
+
+	claims := UserClaims{ID: "revoke-" + time.Now().Format(time.RFC3339Nano), ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	token, err := claims.GetToken(SigningMethodHS512, []byte(secret))
+	assert.NoError(t, err)
+
+	_, valid, err := v.ValidateToken(token)
+	assert.NoError(t, err)
+	assert.True(t, valid)
+
+	// 模拟缓存命中, 吊销后缓存也要失效
+	_ = v.Cache.Add(token, claims)
+
+	assert.NoError(t, v.RevokeToken(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0)))
+	assert.Nil(t, v.Cache.Get(token))
+
+	_, valid, err = v.ValidateToken(token)
+	assert.False(t, valid)
+	assert.True(t, errors.Is(err, ErrTokenRevoked), "%v", err)
+
+	// 其他 validator 实例共享同一个 Revoker 时同样生效, 即使缓存中还有该 token
+	other := NewTokenValidator()
+	other.TokenSecret = secret
+	other.AccessList, _ = newAccessList()
+	other.Revoker = revoker
+	assert.NoError(t, other.ConfigureTokenBackends())
+	_ = other.Cache.Add(token, claims)
+	_, _, err = other.ValidateToken(token)
+	assert.True(t, errors.Is(err, ErrTokenRevoked), "%v", err)
+	assert.Nil(t, other.Cache.Get(token))
+
+	revoked, err := revoker.IsRevoked(ctx, "not-revoked")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	assert.True(t, errors.Is(revoker.Revoke(ctx, "", time.Now()), ErrEmptyTokenID))
+}
+
+func TestMemoryRevoker(t *testing.T) {
+	testRevoker(t, NewMemoryRevoker())
+}
+
+func TestRedisRevoker(t *testing.T) {
+	uri := os.Getenv("REDIS_URI")
+	if uri == "" {
+		t.Skip("REDIS_URI not set")
+	}
+
+	opts, err := redis.ParseURL(uri)
+	assert.NoError(t, err)
+	client := redis.NewClient(opts)
+	defer client.Close()
+
+	testRevoker(t, NewRedisRevoker(client))
+}
+
+func TestRedisRevoker_Miniredis(t *testing.T) {
+	mr, client := newMiniredisClient(t)
+	defer mr.Close()
+	defer client.Close()
+
+	testRevoker(t, NewRedisRevoker(client))
+}
+
+func TestRedisRevoker_NotConnected(t *testing.T) {
+	ctx := context.Background()
+	prev := libsRedis.Client
+	libsRedis.Client = nil
+	defer func() { libsRedis.Client = prev }()
+
+	revoker := NewRedisRevoker(nil)
+	assert.True(t, errors.Is(revoker.Revoke(ctx, "jti", time.Now().Add(time.Hour)), ErrRedisNotConnected))
+	_, err := revoker.IsRevoked(ctx, "jti")
+	assert.True(t, errors.Is(err, ErrRedisNotConnected))
+
+	// 没有连接 redis 时验签返回错误, 不会 panic
+	conf := Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM", Revoker: RevokerRedis}
+	signer, err := NewSignerImplWithConf(conf)
+	assert.NoError(t, err)
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	token, _, err := signer.(Issuer).Issue("user-1", nil, nil)
+	assert.NoError(t, err)
+	_, err = validator.(ContextValidator).VerifyContext(ctx, token)
+	assert.True(t, errors.Is(err, ErrRedisNotConnected), "%v", err)
+}
+
+func TestValidatorImpl_RevokeToken(t *testing.T) {
+	conf := Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM", Revoker: RevokerMemory}
+	signer, err := NewSignerImplWithConf(conf)
+	assert.NoError(t, err)
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+
+	token, expiresAt, err := signer.(Issuer).Issue("user-1", nil, nil)
+	assert.NoError(t, err)
+	claims, err := validator.Verify(token, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, validator.(ValidatorImpl).RevokeToken(context.Background(), claims.ID, expiresAt))
+	_, err = validator.(ContextValidator).VerifyContext(context.Background(), token)
+	assert.True(t, errors.Is(err, ErrTokenRevoked), "%v", err)
+
+	_, err = newJwtOption(Conf{Secret: conf.Secret, Revoker: "file"})
+	// nolint(errorlint): fixme
+	e, ok := err.(ConfErr)
+	if assert.True(t, ok, "%v", err) {
+		assert.Equal(t, ConfErrCodeRevoker, e.Code())
+	}
+}
@@ -3,6 +3,7 @@ package jwt
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -17,12 +18,18 @@ const (
 	ErrInvalidSigningMethod  strError = "unsupported signing method"
 	ErrUnsupportedSecret     strError = "empty secrets are not supported"
 
-	ErrInvalidRole                strError = "invalid role type %T in roles"
-	ErrInvalidRoleType            strError = "invalid roles type %T"
-	ErrInvalidScopesType          strError = "invalid scopes type %T"
-	ErrInvalidOrg                 strError = "invalid org type %T in orgs"
-	ErrInvalidOrgType             strError = "invalid orgs type %T"
-	ErrInvalidAppMetadataRoleType strError = "invalid roles type %T in app_metadata-authorization"
+	ErrInvalidClaimType           strError = "invalid %s claim type %s"
+	ErrInvalidAudience            strError = "invalid audience type %s in aud"
+	ErrInvalidAudienceType        strError = "invalid aud type %s"
+	ErrInvalidRole                strError = "invalid role type %s in roles"
+	ErrInvalidRoleType            strError = "invalid roles type %s"
+	ErrInvalidScope               strError = "invalid scope type %s in scopes"
+	ErrInvalidScopesType          strError = "invalid scopes type %s"
+	ErrInvalidOrg                 strError = "invalid org type %s in orgs"
+	ErrInvalidOrgType             strError = "invalid orgs type %s"
+	ErrInvalidMeta                strError = "invalid meta value type %s for key %q"
+	ErrInvalidMetaType            strError = "invalid meta type %s"
+	ErrInvalidAppMetadataRoleType strError = "invalid roles type %s in app_metadata-authorization"
 
 	ErrInvalidConfiguration        strError = "%s: default access list configuration error: %s"
 	ErrInvalidBackendConfiguration strError = "%s: token validator configuration error: %s"
@@ -31,7 +38,7 @@ const (
 // UserClaims represents custom and standard JWT claims.
 // https://tools.ietf.org/html/rfc7519#section-4.1
 type UserClaims struct {
-	Audience      string            `json:"aud,omitempty" xml:"aud" yaml:"aud,omitempty"`
+	Audience      ClaimStrings      `json:"aud,omitempty" xml:"aud" yaml:"aud,omitempty"`
 	ExpiresAt     int64             `json:"exp,omitempty" xml:"exp" yaml:"exp,omitempty"`
 	ID            string            `json:"jti,omitempty" xml:"jti" yaml:"jti,omitempty"`
 	IssuedAt      int64             `json:"iat,omitempty" xml:"iat" yaml:"iat,omitempty"`
@@ -47,164 +54,158 @@ type UserClaims struct {
 	MetaData      map[string]string `json:"meta,omitempty" xml:"meta" yaml:"meta,omitempty"`
 }
 
+// ClaimStrings RFC 7519 中可以是字符串或者字符串数组的 claim, 例如 aud
+// 只有一个元素时编码为字符串, 兼容只支持字符串 aud 的验签方
+type ClaimStrings []string
+
+// MarshalJSON implements json.Marshaler.
+func (s ClaimStrings) MarshalJSON() ([]byte, error) {
+	if len(s) == 1 {
+		return json.Marshal(s[0])
+	}
+	return json.Marshal([]string(s))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *ClaimStrings) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	values, err := claimStringList(map[string]interface{}{"aud": v}, "aud", ErrInvalidAudience, ErrInvalidAudienceType, false)
+	if err != nil {
+		return err
+	}
+	*s = values
+	return nil
+}
+
+// Contains 检查是否包含 v
+func (s ClaimStrings) Contains(v string) bool {
+	return containsString(s, v)
+}
+
 // Valid validates user claims.
+// 和 jwtlib.MapClaims 一致, 没有 exp/nbf/iat 的 token 不检查对应的时间
 func (u UserClaims) Valid() error {
-	if u.ExpiresAt < time.Now().Unix() {
-		return errors.New("token expired")
+	if vErr := u.validAt(time.Now(), 0, false); vErr != nil {
+		return vErr
 	}
 	return nil
 }
 
-// NewUserClaimsFromMap returns UserClaims.
-func NewUserClaimsFromMap(m map[string]interface{}) (*UserClaims, error) {
-	u := &UserClaims{}
+// validAt 检查 exp, nbf, iat, leeway 用于容忍签发方和验证方之间的时钟偏差
+// 返回的错误可能同时包含多个错误位, 没有错误时返回 nil
+func (u UserClaims) validAt(now time.Time, leeway time.Duration, requireExp bool) *jwtlib.ValidationError {
+	vErr := new(jwtlib.ValidationError)
+	skew := int64(leeway / time.Second)
+	ts := now.Unix()
 
-	if _, exists := m["aud"]; exists {
-		u.Audience = m["aud"].(string)
-	}
-	if _, exists := m["exp"]; exists {
-		switch exp := m["exp"].(type) {
-		case float64:
-			u.ExpiresAt = int64(exp)
-		case json.Number:
-			v, _ := exp.Int64()
-			u.ExpiresAt = v
-		default:
-			return nil, ErrInvalidClaimExpiresAt
-		}
+	switch {
+	case u.ExpiresAt == 0 && requireExp:
+		vErr.Inner = errors.New("token has no exp")
+		vErr.Errors |= jwtlib.ValidationErrorExpired
+	case u.ExpiresAt != 0 && ts > u.ExpiresAt+skew:
+		vErr.Inner = errors.New("token is expired")
+		vErr.Errors |= jwtlib.ValidationErrorExpired
 	}
 
-	if _, exists := m["jti"]; exists {
-		u.ID = m["jti"].(string)
+	if u.IssuedAt != 0 && ts+skew < u.IssuedAt {
+		vErr.Inner = errors.New("token used before issued")
+		vErr.Errors |= jwtlib.ValidationErrorIssuedAt
 	}
 
-	if _, exists := m["iat"]; exists {
-		switch exp := m["iat"].(type) {
-		case float64:
-			u.IssuedAt = int64(exp)
-		case json.Number:
-			v, _ := exp.Int64()
-			u.IssuedAt = v
-		default:
-			return nil, ErrInvalidClaimIssuedAt
-		}
+	if u.NotBefore != 0 && ts+skew < u.NotBefore {
+		vErr.Inner = errors.New("token is not valid yet")
+		vErr.Errors |= jwtlib.ValidationErrorNotValidYet
 	}
 
-	if _, exists := m["iss"]; exists {
-		u.Issuer = m["iss"].(string)
+	if vErr.Errors == 0 {
+		return nil
 	}
+	return vErr
+}
 
-	if _, exists := m["nbf"]; exists {
-		switch exp := m["nbf"].(type) {
-		case float64:
-			u.NotBefore = int64(exp)
-		case json.Number:
-			v, _ := exp.Int64()
-			u.NotBefore = v
-		default:
-			return nil, ErrInvalidClaimNotBefore
-		}
-	}
+// NewUserClaimsFromMap returns UserClaims.
+// 没有 roles 的 token 会被赋予 anonymous 和 guest 角色
+func NewUserClaimsFromMap(m map[string]interface{}) (*UserClaims, error) {
+	return newUserClaimsFromMap(m, true)
+}
 
-	if _, exists := m["sub"]; exists {
-		u.Subject = m["sub"].(string)
-	}
+// NewStrictUserClaimsFromMap 和 NewUserClaimsFromMap 相同, 但是不给没有 roles 的 token 添加默认角色
+// 和 StrictRoles 为 true 的 TokenValidator 一致
+func NewStrictUserClaimsFromMap(m map[string]interface{}) (*UserClaims, error) {
+	return newUserClaimsFromMap(m, false)
+}
 
-	if _, exists := m["name"]; exists {
-		u.Name = m["name"].(string)
-	}
+// newUserClaimsFromMap defaultRoles 为 false 时不给没有 roles 的 token 添加默认角色
+// 类型不符合预期的 claim 会返回对应的错误, 值为 null 的 claim 当作不存在
+func newUserClaimsFromMap(m map[string]interface{}, defaultRoles bool) (*UserClaims, error) {
+	u := &UserClaims{}
+	var err error
 
-	if _, exists := m["mail"]; exists {
-		u.Email = m["mail"].(string)
+	if u.Audience, err = claimStringList(m, "aud", ErrInvalidAudience, ErrInvalidAudienceType, false); err != nil {
+		return nil, err
 	}
-
-	if _, exists := m["email"]; exists {
-		u.Email = m["email"].(string)
+	if u.ExpiresAt, err = claimNumericDate(m, "exp", ErrInvalidClaimExpiresAt); err != nil {
+		return nil, err
 	}
-
-	if _, exists := m["roles"]; exists {
-		switch m["roles"].(type) {
-		case []interface{}:
-			roles := m["roles"].([]interface{})
-			for _, role := range roles {
-				switch role := role.(type) {
-				case string:
-					u.Roles = append(u.Roles, role)
-				default:
-					return nil, ErrInvalidRole.WithArgs(role)
-				}
-			}
-		case string:
-			roles := m["roles"].(string)
-			u.Roles = append(u.Roles, strings.Split(roles, " ")...)
-		default:
-			return nil, ErrInvalidRoleType.WithArgs(m["roles"])
-		}
+	if u.ID, err = claimString(m, "jti"); err != nil {
+		return nil, err
 	}
-
-	if _, exists := m["origin"]; exists {
-		u.Origin = m["origin"].(string)
+	if u.IssuedAt, err = claimNumericDate(m, "iat", ErrInvalidClaimIssuedAt); err != nil {
+		return nil, err
 	}
-
-	if _, exists := m["scopes"]; exists {
-		switch m["scopes"].(type) {
-		case []interface{}:
-			scopes := m["scopes"].([]interface{})
-			for _, scope := range scopes {
-				switch scope := scope.(type) {
-				case string:
-					u.Scopes = append(u.Scopes, scope)
-				default:
-					return nil, ErrInvalidScopesType.WithArgs(scope)
-				}
-			}
-		case string:
-			scopes := m["scopes"].(string)
-			u.Scopes = append(u.Scopes, strings.Split(scopes, " ")...)
-		default:
-			return nil, ErrInvalidScopesType.WithArgs(m["scopes"])
-		}
+	if u.Issuer, err = claimString(m, "iss"); err != nil {
+		return nil, err
 	}
-
-	if _, exists := m["org"]; exists {
-		switch m["org"].(type) {
-		case []interface{}:
-			orgs := m["org"].([]interface{})
-			for _, org := range orgs {
-				switch org := org.(type) {
-				case string:
-					u.Organizations = append(u.Organizations, org)
-				default:
-					return nil, ErrInvalidOrg.WithArgs(org)
-				}
-			}
-		case string:
-			orgs := m["org"].(string)
-			u.Organizations = append(u.Organizations, strings.Split(orgs, " ")...)
-		default:
-			return nil, ErrInvalidOrgType.WithArgs(m["org"])
-		}
+	if u.NotBefore, err = claimNumericDate(m, "nbf", ErrInvalidClaimNotBefore); err != nil {
+		return nil, err
+	}
+	if u.Subject, err = claimString(m, "sub"); err != nil {
+		return nil, err
+	}
+	if u.Name, err = claimString(m, "name"); err != nil {
+		return nil, err
+	}
+	if u.Email, err = claimString(m, "mail"); err != nil {
+		return nil, err
+	}
+	if email, err := claimString(m, "email"); err != nil {
+		return nil, err
+	} else if email != "" {
+		u.Email = email
+	}
+	if u.Roles, err = claimStringList(m, "roles", ErrInvalidRole, ErrInvalidRoleType, true); err != nil {
+		return nil, err
+	}
+	if u.Origin, err = claimString(m, "origin"); err != nil {
+		return nil, err
+	}
+	if u.Scopes, err = claimStringList(m, "scopes", ErrInvalidScope, ErrInvalidScopesType, true); err != nil {
+		return nil, err
+	}
+	if u.Organizations, err = claimStringList(m, "org", ErrInvalidOrg, ErrInvalidOrgType, true); err != nil {
+		return nil, err
 	}
 
 	u.MetaData = make(map[string]string)
-	if _, exists := m["meta"]; exists {
-		switch m["meta"].(type) {
-		case map[string]interface{}:
-			meta := m["meta"].(map[string]interface{})
-			for k, v := range meta {
-				switch v := v.(type) {
-				case string:
-					u.MetaData[k] = v
-				default:
-					return nil, ErrInvalidOrg.WithArgs(v)
-				}
+	if v := m["meta"]; v != nil {
+		meta, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, ErrInvalidMetaType.WithArgs(typeName(v))
+		}
+		for k, v := range meta {
+			value, ok := v.(string)
+			if !ok {
+				return nil, ErrInvalidMeta.WithArgs(typeName(v), k)
 			}
-		default:
-			return nil, ErrInvalidOrgType.WithArgs(m["meta"])
+			u.MetaData[k] = value
 		}
 	}
 
-	if len(u.Roles) == 0 {
+	if defaultRoles && len(u.Roles) == 0 {
 		u.Roles = append(u.Roles, "anonymous")
 		u.Roles = append(u.Roles, "guest")
 	}
@@ -212,22 +213,82 @@ func NewUserClaimsFromMap(m map[string]interface{}) (*UserClaims, error) {
 	return u, nil
 }
 
-func (u *UserClaims) FromJSON(data []byte) error {
-	return json.Unmarshal(data, u)
+// typeName 返回 v 的类型, 用于错误信息, strError.WithArgs 遇到 nil 参数时会返回 nil
+func typeName(v interface{}) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
 }
 
-// GetToken returns a signed JWT token
-func (u *UserClaims) GetToken(method SigningMethod, secret interface{}) (string, error) {
-	if secret == nil {
-		return "", ErrUnsupportedSecret
+func claimString(m map[string]interface{}, key string) (string, error) {
+	v := m[key]
+	if v == nil {
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", ErrInvalidClaimType.WithArgs(key, typeName(v))
 	}
+	return s, nil
+}
 
-	sm := method.getSigningMethod()
+// claimNumericDate 解析 RFC 7519 的 NumericDate, 支持 json.Unmarshal 和 json.Decoder.UseNumber 的结果
+func claimNumericDate(m map[string]interface{}, key string, typeErr error) (int64, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if f, err := v.Float64(); err == nil {
+			return int64(f), nil
+		}
+		return 0, typeErr
+	default:
+		return 0, typeErr
+	}
+}
 
-	token := jwtlib.NewWithClaims(sm, u)
-	tokenString, err := token.SignedString(secret)
-	if err != nil {
-		return "", err
+// claimStringList 解析字符串数组, split 为 true 时字符串按空格分隔, 否则作为只有一个元素的数组
+func claimStringList(m map[string]interface{}, key string, elemErr, typeErr strError, split bool) ([]string, error) {
+	switch v := m[key].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if split {
+			return strings.Split(v, " "), nil
+		}
+		return []string{v}, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, elem := range v {
+			s, ok := elem.(string)
+			if !ok {
+				return nil, elemErr.WithArgs(typeName(elem))
+			}
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return nil, typeErr.WithArgs(typeName(v))
 	}
-	return tokenString, nil
+}
+
+func (u *UserClaims) FromJSON(data []byte) error {
+	return json.Unmarshal(data, u)
+}
+
+// GetToken returns a signed JWT token
+func (u *UserClaims) GetToken(method SigningMethod, secret interface{}) (string, error) {
+	return signClaims(u, method, "", secret)
 }
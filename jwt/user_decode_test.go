@@ -0,0 +1,225 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// claimsCorpus 解析 claims 的语料, 覆盖各个 claim 类型不符合预期的情况
+const claimsCorpus = "testdata/fuzz/claims"
+
+// decodeClaimsNoPanic 使用所有解析 claims 的方式解析 data, 解析过程中不能 panic
+func decodeClaimsNoPanic(t *testing.T, data []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("panic decoding claims %q: %v", data, r)
+		}
+	}()
+
+	check := func(m map[string]interface{}) {
+		claims, err := NewUserClaimsFromMap(m)
+		if (claims == nil) == (err == nil) {
+			t.Fatalf("claims %v and error %v for %q", claims, err, data)
+		}
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err == nil {
+		check(m)
+	}
+
+	m = nil
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&m); err == nil {
+		check(m)
+	}
+
+	u := UserClaims{}
+	_ = u.FromJSON(data)
+	_ = decodeClaims("e30."+jwtlib.EncodeSegment(data)+".sig", &u)
+}
+
+func loadClaimsCorpus(t *testing.T) map[string][]byte {
+	files, err := filepath.Glob(filepath.Join(claimsCorpus, "*.json"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	corpus := make(map[string][]byte, len(files))
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		assert.NoError(t, err)
+		corpus[filepath.Base(f)] = data
+	}
+	return corpus
+}
+
+func TestNewUserClaimsFromMap_Corpus(t *testing.T) {
+	corpus := loadClaimsCorpus(t)
+
+	expected := map[string]error{
+		"valid.json":            nil,
+		"aud_array.json":        nil,
+		"nulls.json":            nil,
+		"huge_dates.json":       nil,
+		"aud_number.json":       ErrInvalidAudienceType,
+		"aud_array_mixed.json":  ErrInvalidAudience,
+		"wrong_strings.json":    ErrInvalidClaimType,
+		"wrong_dates.json":      ErrInvalidClaimExpiresAt,
+		"wrong_list_elems.json": ErrInvalidRole,
+		"wrong_lists.json":      ErrInvalidRoleType,
+		"meta_string.json":      ErrInvalidMetaType,
+		"meta_values.json":      ErrInvalidMeta,
+		"email.json":            ErrInvalidClaimType,
+	}
+
+	for name, data := range corpus {
+		decodeClaimsNoPanic(t, data)
+
+		want, ok := expected[name]
+		if !ok {
+			continue
+		}
+		m := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(data, &m), name)
+		claims, err := NewUserClaimsFromMap(m)
+		if want == nil {
+			assert.NoError(t, err, name)
+			assert.NotNil(t, claims, name)
+			continue
+		}
+		assert.True(t, errors.Is(err, want), "%s: %v", name, err)
+	}
+}
+
+// TestNewUserClaimsFromMap_Fuzz 对语料随机变异, 以及为每个 claim 生成随机类型的值
+func TestNewUserClaimsFromMap_Fuzz(t *testing.T) {
+	corpus := loadClaimsCorpus(t)
+	r := rand.New(rand.NewSource(1))
+	alphabet := []byte(`{}[]",:-.0123456789eE truefalsnul\`)
+
+	for _, data := range corpus {
+		for i := 0; i < 200; i++ {
+			mutated := append([]byte(nil), data...)
+			for n := r.Intn(4) + 1; n > 0 && len(mutated) > 0; n-- {
+				pos := r.Intn(len(mutated))
+				switch r.Intn(3) {
+				case 0:
+					mutated[pos] = alphabet[r.Intn(len(alphabet))]
+				case 1:
+					mutated = append(mutated[:pos], mutated[pos+1:]...)
+				default:
+					mutated = append(mutated[:pos], append([]byte{alphabet[r.Intn(len(alphabet))]}, mutated[pos:]...)...)
+				}
+			}
+			decodeClaimsNoPanic(t, mutated)
+		}
+	}
+
+	keys := []string{"aud", "exp", "jti", "iat", "iss", "nbf", "sub", "name", "mail", "email",
+		"roles", "origin", "scopes", "org", "meta"}
+	for i := 0; i < 2000; i++ {
+		m := map[string]interface{}{}
+		for _, k := range keys {
+			if r.Intn(2) == 0 {
+				m[k] = randomJSONValue(r, 2)
+			}
+		}
+		data, err := json.Marshal(m)
+		assert.NoError(t, err)
+		decodeClaimsNoPanic(t, data)
+	}
+}
+
+func randomJSONValue(r *rand.Rand, depth int) interface{} {
+	n := 6
+	if depth > 0 {
+		n = 8
+	}
+	switch r.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return r.Intn(2) == 0
+	case 2:
+		return r.NormFloat64() * 1e10
+	case 3:
+		return "v"
+	case 4:
+		return ""
+	case 5:
+		return "a b"
+	case 6:
+		arr := make([]interface{}, r.Intn(3))
+		for i := range arr {
+			arr[i] = randomJSONValue(r, depth-1)
+		}
+		return arr
+	default:
+		obj := map[string]interface{}{}
+		for i := r.Intn(3); i > 0; i-- {
+			obj[string(rune('a'+r.Intn(3)))] = randomJSONValue(r, depth-1)
+		}
+		return obj
+	}
+}
+
+func TestClaimStrings_JSON(t *testing.T) {
+	cases := []struct {
+		data     string
+		expected ClaimStrings
+		encoded  string
+	}{
+		{`{"aud":"api"}`, ClaimStrings{"api"}, `{"aud":"api"}`},
+		{`{"aud":["api","web"]}`, ClaimStrings{"api", "web"}, `{"aud":["api","web"]}`},
+		{`{"aud":null}`, nil, `{}`},
+	}
+
+	for _, tc := range cases {
+		u := UserClaims{}
+		assert.NoError(t, u.FromJSON([]byte(tc.data)), tc.data)
+		assert.Equal(t, tc.expected, u.Audience, tc.data)
+
+		data, err := json.Marshal(struct {
+			Audience ClaimStrings `json:"aud,omitempty"`
+		}{u.Audience})
+		assert.NoError(t, err)
+		assert.Equal(t, tc.encoded, string(data))
+	}
+
+	u := UserClaims{}
+	err := u.FromJSON([]byte(`{"aud":[1]}`))
+	assert.True(t, errors.Is(err, ErrInvalidAudience), "%v", err)
+}
+
+func TestTokenValidator_AudienceArray(t *testing.T) {
+	secret := "75f03764-147c-4d87-b2f0-4fda89e331c8"
+	v := NewTokenValidator()
+	defer v.Close()
+	v.TokenSecret = secret
+	v.AccessList, _ = newAccessList()
+	v.ExpectedAudiences = []string{"web"}
+	assert.NoError(t, v.ConfigureTokenBackends())
+
+	for aud, valid := range map[string]bool{"api": false, "web": true} {
+		claims := UserClaims{Audience: ClaimStrings{aud, "mobile"}, ExpiresAt: time.Now().Add(time.Minute).Unix()}
+		token, err := claims.GetToken(SigningMethodHS512, []byte(secret))
+		assert.NoError(t, err)
+
+		verified, ok, err := v.ValidateToken(token)
+		assert.Equal(t, valid, ok, aud)
+		if valid {
+			assert.NoError(t, err)
+			assert.Equal(t, claims.Audience, verified.Audience)
+		}
+	}
+}
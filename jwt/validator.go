@@ -1,10 +1,14 @@
 package jwt
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"time"
 
 	jwtlib "github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
 )
 
 // Validator Errors
@@ -19,17 +23,51 @@ const (
 	ErrInvalidParsedClaims strError = "failed to extract claims: %s"
 	ErrInvalidSecret       strError = "secret key backend error: %s"
 	ErrInvalid             strError = "%v"
+	ErrUnexpectedIssuer    strError = "unexpected issuer: %q"
+	ErrUnexpectedAudience  strError = "unexpected audience: %q"
 )
 
 // TokenValidator validates tokens in http requests.
 type TokenValidator struct {
 	CommonTokenConfig
+	// Cache 缓存验证通过的 token, 再次验证时跳过验签, 为 nil 时不使用缓存
 	Cache         *TokenCache
-	AccessList    []*AccessListEntry
+	AccessList    AccessList
 	TokenBackends []TokenBackend
 
+	// AccessListDryRun 为 true 时只记录 access list 的检查结果, 不拒绝请求, 用于上线新的 access list
+	AccessListDryRun bool
+	// Logger 记录 dry run 的检查结果, 为 nil 时使用 zap.L()
+	Logger *zap.Logger
+
+	// Keyring 不为空时按 kid 选择验签密钥
+	Keyring *Keyring
+	// JWKSURL 不为空时从远程 JWKS 获取验签公钥
+	JWKSURL string
+	// AllowedSigningMethods 验签允许的 alg, 为空时使用 TokenSigningMethod
+	AllowedSigningMethods []string
+
+	// ExpectedIssuers 不为空时 iss 必须是其中之一
+	ExpectedIssuers []string
+	// ExpectedAudiences 不为空时 aud 中至少有一个是其中之一
+	ExpectedAudiences []string
+	// Leeway 检查 exp, nbf, iat 时允许的时钟偏差
+	Leeway time.Duration
+	// AllowMissingExpiration 为 true 时接受没有 exp 的 token, 默认拒绝
+	AllowMissingExpiration bool
+
+	// Revoker 不为空时验签通过后检查 jti 是否已经被吊销
+	Revoker Revoker
+	// StrictRoles 为 true 时不给没有 roles 的 token 添加 anonymous/guest 角色
+	StrictRoles bool
+
 	PrivateKey *rsa.PrivateKey
 	PublicKey  *rsa.PublicKey
+
+	ECPrivateKey *ecdsa.PrivateKey
+	ECPublicKey  *ecdsa.PublicKey
+	EdPrivateKey ed25519.PrivateKey
+	EdPublicKey  ed25519.PublicKey
 }
 
 // NewTokenValidator returns an instance of TokenValidator
@@ -44,10 +82,32 @@ func NewTokenValidator() *TokenValidator {
 // ConfigureTokenBackends configures available TokenBackend.
 func (v *TokenValidator) ConfigureTokenBackends() error {
 	v.TokenBackends = []TokenBackend{}
+	if v.Keyring != nil {
+		backend, err := NewKeyringTokenBackend(v.Keyring)
+		if err != nil {
+			return ErrInvalidSecret.WithArgs(err)
+		}
+		v.TokenBackends = append(v.TokenBackends, backend)
+	}
+	if v.JWKSURL != "" {
+		backend, err := NewJWKSTokenBackend(v.JWKSURL)
+		if err != nil {
+			return err
+		}
+		v.TokenBackends = append(v.TokenBackends, backend)
+	}
 	if v.PrivateKey != nil || v.PublicKey != nil {
 		backend := NewRSAKeyTokenBackend(v.PrivateKey, v.PublicKey)
 		v.TokenBackends = append(v.TokenBackends, backend)
 	}
+	if v.ECPrivateKey != nil || v.ECPublicKey != nil {
+		backend := NewECDSAKeyTokenBackend(v.ECPrivateKey, v.ECPublicKey)
+		v.TokenBackends = append(v.TokenBackends, backend)
+	}
+	if v.EdPrivateKey != nil || v.EdPublicKey != nil {
+		backend := NewEd25519KeyTokenBackend(v.EdPrivateKey, v.EdPublicKey)
+		v.TokenBackends = append(v.TokenBackends, backend)
+	}
 	if v.TokenSecret != "" {
 		backend, err := NewSecretKeyTokenBackend(v.TokenSecret)
 		if err != nil {
@@ -63,24 +123,36 @@ func (v *TokenValidator) ConfigureTokenBackends() error {
 
 // ValidateToken parses a token and returns claims, if valid.
 func (v *TokenValidator) ValidateToken(token string) (*UserClaims, bool, error) {
+	return v.ValidateTokenContext(context.Background(), token)
+}
+
+// ValidateTokenContext 和 ValidateToken 相同, ctx 用于检查 token 是否被吊销
+func (v *TokenValidator) ValidateTokenContext(ctx context.Context, token string) (*UserClaims, bool, error) {
 	valid := false
+	cached := false
 	// First, check cached entries
-	claims := v.Cache.Get(token)
+	var claims *UserClaims
+	if v.Cache != nil {
+		claims = v.Cache.get(token, time.Now(), v.Leeway, !v.AllowMissingExpiration)
+	}
 	if claims != nil {
-		if claims.ExpiresAt < time.Now().Unix() {
+		if err := v.validateClaims(claims); err != nil {
 			_ = v.Cache.Delete(token)
-			return nil, false, ErrExpiredToken
+			return nil, false, err
 		}
 		valid = true
+		cached = true
 	}
 
 	parseErrors := []error{}
 	// If not valid, parse claims from a string.
 	if !valid {
+		// 标准声明在 validateClaims 中统一检查, 以支持 leeway
+		parser := &jwtlib.Parser{ValidMethods: v.validMethods(), SkipClaimsValidation: true}
 		for _, backend := range v.TokenBackends {
 			mapClaims := jwtlib.MapClaims{}
 
-			token, err := jwtlib.ParseWithClaims(token, &mapClaims, backend.ProvideKey)
+			token, err := parser.ParseWithClaims(token, &mapClaims, backend.ProvideKey)
 			if err != nil {
 				parseErrors = append(parseErrors, err)
 				continue
@@ -93,42 +165,182 @@ func (v *TokenValidator) ValidateToken(token string) (*UserClaims, bool, error)
 				continue
 			}
 
-			claims, err = NewUserClaimsFromMap(mapClaims)
+			claims, err = newUserClaimsFromMap(mapClaims, !v.StrictRoles)
 			if err != nil {
 				parseErrors = append(parseErrors, ErrInvalidParsedClaims.WithArgs(err))
 				continue
 			}
 
+			if err := v.validateClaims(claims); err != nil {
+				return nil, false, err
+			}
+
 			valid = true
 			break
 		}
 	}
 
 	if valid {
-		if len(v.AccessList) == 0 {
-			return nil, false, ErrNoAccessList
+		if err := v.checkRevoked(ctx, token, claims); err != nil {
+			return nil, false, err
 		}
-		aclAllowed := false
-		for _, entry := range v.AccessList {
-			claimAllowed, abortProcessing := entry.IsClaimAllowed(claims)
-			if abortProcessing {
-				aclAllowed = claimAllowed
-				break
-			}
 
-			if claimAllowed {
-				aclAllowed = true
-			}
-		}
-		if !aclAllowed {
-			return nil, false, ErrAccessNotAllowed
+		if err := v.checkAccessList(claims); err != nil {
+			return nil, false, err
 		}
 	}
 
 	if !valid {
-		// return nil, false, ErrInvalid.WithArgs(errorMessages)
-		return nil, false, parseErrors[0]
+		if len(parseErrors) == 0 {
+			return nil, false, ErrNoBackends
+		}
+		// 所有 backend 都失败时返回全部错误, 最具体的错误排在最前面
+		return nil, false, newMultiError(parseErrors)
+	}
+
+	if v.Cache != nil && !cached {
+		_ = v.Cache.Add(token, *claims)
 	}
 
 	return claims, true, nil
 }
+
+// Close 释放 TokenValidator 占用的资源, 例如缓存的清理 goroutine
+func (v *TokenValidator) Close() error {
+	if v.Cache == nil {
+		return nil
+	}
+	return v.Cache.Close()
+}
+
+// RevokeToken 吊销 jti 并清除缓存中对应的 token
+func (v *TokenValidator) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	if v.Revoker == nil {
+		return ErrNoRevoker
+	}
+	if err := v.Revoker.Revoke(ctx, jti, expiresAt); err != nil {
+		return err
+	}
+	if v.Cache == nil {
+		return nil
+	}
+	return v.Cache.DeleteID(jti)
+}
+
+// checkRevoked 检查 jti 是否已经被吊销, 被吊销的 token 会从缓存中清除
+func (v *TokenValidator) checkRevoked(ctx context.Context, token string, claims *UserClaims) error {
+	if v.Revoker == nil || claims.ID == "" {
+		return nil
+	}
+
+	revoked, err := v.Revoker.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		if v.Cache != nil {
+			_ = v.Cache.Delete(token)
+		}
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Evaluate 使用 access list 检查 claims, 返回决定结果的 entry 和匹配的 claim 值
+func (v *TokenValidator) Evaluate(claims *UserClaims) ACLDecision {
+	return v.AccessList.Evaluate(claims)
+}
+
+// checkAccessList 检查 claims 是否被 access list 允许, dry run 时只记录结果
+func (v *TokenValidator) checkAccessList(claims *UserClaims) error {
+	var err error
+	decision := v.Evaluate(claims)
+	switch {
+	case len(v.AccessList) == 0:
+		err = ErrNoAccessList
+	case !decision.Allowed:
+		err = ErrAccessNotAllowed
+	}
+
+	if !v.AccessListDryRun {
+		return err
+	}
+
+	fields := []zap.Field{
+		zap.Bool("allowed", decision.Allowed),
+		zap.Int("index", decision.Index),
+		zap.String("value", decision.Value),
+		zap.String("pattern", decision.Pattern),
+		zap.String("sub", claims.Subject),
+		zap.String("jti", claims.ID),
+	}
+	if decision.Entry != nil {
+		fields = append(fields,
+			zap.String("action", decision.Entry.Action),
+			zap.String("claim", decision.Entry.Claim))
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	v.logger().Info("jwt access list dry run", fields...)
+	return nil
+}
+
+func (v *TokenValidator) logger() *zap.Logger {
+	if v.Logger != nil {
+		return v.Logger
+	}
+	return zap.L()
+}
+
+// validMethods 返回允许的签名算法, nil 表示不限制
+func (v *TokenValidator) validMethods() []string {
+	if len(v.AllowedSigningMethods) > 0 {
+		return v.AllowedSigningMethods
+	}
+	if v.TokenSigningMethod != "" {
+		return []string{v.TokenSigningMethod}
+	}
+	return nil
+}
+
+// validateClaims 检查 exp, nbf, iat, iss, aud, 错误统一使用 *jwtlib.ValidationError 表示
+func (v *TokenValidator) validateClaims(claims *UserClaims) error {
+	vErr := claims.validAt(time.Now(), v.Leeway, !v.AllowMissingExpiration)
+	if vErr == nil {
+		vErr = new(jwtlib.ValidationError)
+	}
+
+	if len(v.ExpectedIssuers) > 0 && !containsString(v.ExpectedIssuers, claims.Issuer) {
+		vErr.Inner = ErrUnexpectedIssuer.WithArgs(claims.Issuer)
+		vErr.Errors |= jwtlib.ValidationErrorIssuer
+	}
+
+	if len(v.ExpectedAudiences) > 0 && !containsAnyString(v.ExpectedAudiences, claims.Audience) {
+		vErr.Inner = ErrUnexpectedAudience.WithArgs([]string(claims.Audience))
+		vErr.Errors |= jwtlib.ValidationErrorAudience
+	}
+
+	if vErr.Errors == 0 {
+		return nil
+	}
+	return vErr
+}
+
+func containsString(arr []string, s string) bool {
+	for _, v := range arr {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAnyString(arr []string, values []string) bool {
+	for _, v := range values {
+		if containsString(arr, v) {
+			return true
+		}
+	}
+	return false
+}
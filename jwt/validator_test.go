@@ -0,0 +1,252 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenValidator_ValidateClaims(t *testing.T) {
+	secret := "75f03764-147c-4d87-b2f0-4fda89e331c8"
+	now := time.Now()
+	exp := now.Add(time.Minute).Unix()
+
+	cases := []struct {
+		caseName string
+		setup    func(v *TokenValidator)
+		claims   UserClaims
+		errors   uint32
+	}{
+		{
+			"valid",
+			nil,
+			UserClaims{ExpiresAt: now.Add(time.Minute).Unix()},
+			0,
+		},
+		{
+			"no exp",
+			nil,
+			UserClaims{},
+			jwtlib.ValidationErrorExpired,
+		},
+		{
+			"no exp but allowed",
+			func(v *TokenValidator) { v.AllowMissingExpiration = true },
+			UserClaims{},
+			0,
+		},
+		{
+			"expired",
+			nil,
+			UserClaims{ExpiresAt: now.Add(-time.Minute).Unix()},
+			jwtlib.ValidationErrorExpired,
+		},
+		{
+			"expired within leeway",
+			func(v *TokenValidator) { v.Leeway = 2 * time.Minute },
+			UserClaims{ExpiresAt: now.Add(-time.Minute).Unix()},
+			0,
+		},
+		{
+			"not valid yet",
+			nil,
+			UserClaims{ExpiresAt: exp, NotBefore: now.Add(time.Minute).Unix()},
+			jwtlib.ValidationErrorNotValidYet,
+		},
+		{
+			"not valid yet within leeway",
+			func(v *TokenValidator) { v.Leeway = 2 * time.Minute },
+			UserClaims{ExpiresAt: exp, NotBefore: now.Add(time.Minute).Unix()},
+			0,
+		},
+		{
+			"issued in the future",
+			nil,
+			UserClaims{ExpiresAt: exp, IssuedAt: now.Add(time.Minute).Unix()},
+			jwtlib.ValidationErrorIssuedAt,
+		},
+		{
+			"issuer",
+			func(v *TokenValidator) { v.ExpectedIssuers = []string{"auth-1", "auth-2"} },
+			UserClaims{ExpiresAt: exp, Issuer: "auth-2"},
+			0,
+		},
+		{
+			"unexpected issuer",
+			func(v *TokenValidator) { v.ExpectedIssuers = []string{"auth-1", "auth-2"} },
+			UserClaims{ExpiresAt: exp, Issuer: "evil"},
+			jwtlib.ValidationErrorIssuer,
+		},
+		{
+			"audience",
+			func(v *TokenValidator) { v.ExpectedAudiences = []string{"api"} },
+			UserClaims{ExpiresAt: exp, Audience: ClaimStrings{"api"}},
+			0,
+		},
+		{
+			"unexpected audience",
+			func(v *TokenValidator) { v.ExpectedAudiences = []string{"api"} },
+			UserClaims{ExpiresAt: exp},
+			jwtlib.ValidationErrorAudience,
+		},
+		{
+			"expired with unexpected audience",
+			func(v *TokenValidator) { v.ExpectedAudiences = []string{"api"} },
+			UserClaims{ExpiresAt: now.Add(-time.Minute).Unix(), Audience: ClaimStrings{"web"}},
+			jwtlib.ValidationErrorExpired | jwtlib.ValidationErrorAudience,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Logf("case: %s", tc.caseName)
+
+		v := NewTokenValidator()
+		v.TokenSecret = secret
+		v.AccessList, _ = newAccessList()
+		if tc.setup != nil {
+			tc.setup(v)
+		}
+		assert.NoError(t, v.ConfigureTokenBackends())
+
+		token, err := tc.claims.GetToken(SigningMethodHS512, []byte(secret))
+		assert.NoError(t, err)
+
+		_, valid, err := v.ValidateToken(token)
+		if tc.errors == 0 {
+			assert.NoError(t, err)
+			assert.True(t, valid)
+			continue
+		}
+
+		assert.False(t, valid)
+		// nolint(errorlint): fixme
+		vErr, ok := err.(*jwtlib.ValidationError)
+		if assert.True(t, ok, "%v", err) {
+			assert.Equal(t, tc.errors, vErr.Errors)
+		}
+	}
+}
+
+func TestTokenValidator_ValidateToken_CachedExpired(t *testing.T) {
+	secret := "75f03764-147c-4d87-b2f0-4fda89e331c8"
+	v := NewTokenValidator()
+	v.TokenSecret = secret
+	v.AccessList, _ = newAccessList()
+	assert.NoError(t, v.ConfigureTokenBackends())
+
+	claims := UserClaims{ExpiresAt: time.Now().Add(time.Minute).Unix(), Issuer: "evil"}
+	token, err := claims.GetToken(SigningMethodHS512, []byte(secret))
+	assert.NoError(t, err)
+	_ = v.Cache.Add(token, claims)
+
+	// 缓存中的 claims 同样需要通过 iss 检查
+	v.ExpectedIssuers = []string{"auth"}
+	_, valid, err := v.ValidateToken(token)
+	assert.False(t, valid)
+	assert.Error(t, err)
+	assert.Nil(t, v.Cache.Get(token))
+}
+
+// countingTokenBackend 记录 ProvideKey 的调用次数, 即验签的次数
+type countingTokenBackend struct {
+	TokenBackend
+	calls int
+}
+
+func (b *countingTokenBackend) ProvideKey(token *jwtlib.Token) (interface{}, error) {
+	b.calls++
+	return b.TokenBackend.ProvideKey(token)
+}
+
+func TestTokenValidator_ValidateToken_Cache(t *testing.T) {
+	secret := "75f03764-147c-4d87-b2f0-4fda89e331c8"
+	claims := UserClaims{ID: "123456", ExpiresAt: time.Now().Add(time.Minute).Unix()}
+	token, err := claims.GetToken(SigningMethodHS512, []byte(secret))
+	assert.NoError(t, err)
+
+	for _, disabled := range []bool{false, true} {
+		t.Logf("case: cache disabled %v", disabled)
+
+		v := NewTokenValidator()
+		v.TokenSecret = secret
+		v.AccessList, _ = newAccessList()
+		if disabled {
+			_ = v.Close()
+			v.Cache = nil
+		}
+		assert.NoError(t, v.ConfigureTokenBackends())
+		backend := &countingTokenBackend{TokenBackend: v.TokenBackends[0]}
+		v.TokenBackends[0] = backend
+
+		for i := 0; i < 3; i++ {
+			verified, valid, err := v.ValidateToken(token)
+			assert.NoError(t, err)
+			assert.True(t, valid)
+			if assert.NotNil(t, verified) {
+				assert.Equal(t, claims.ID, verified.ID)
+			}
+		}
+
+		if disabled {
+			assert.Equal(t, 3, backend.calls)
+			continue
+		}
+		assert.Equal(t, 1, backend.calls)
+		assert.Equal(t, uint64(2), v.Cache.Stats().Hits)
+		// 验签失败的 token 不会被缓存
+		_, valid, _ := v.ValidateToken(token + "x")
+		assert.False(t, valid)
+		assert.Equal(t, 1, v.Cache.Len())
+		_ = v.Close()
+	}
+}
+
+func TestValidatorImpl_DisableTokenCache(t *testing.T) {
+	conf := Conf{Secret: "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM", TokenCacheSize: 10}
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, validator.(ValidatorImpl).tokenValidator.Cache.MaxEntries)
+
+	conf.DisableTokenCache = true
+	validator, err = NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+	assert.Nil(t, validator.(ValidatorImpl).tokenValidator.Cache)
+}
+
+func TestTokenValidator_ValidateToken_AllBackendsFail(t *testing.T) {
+	secretBackend, err := NewSecretKeyTokenBackend("75f03764-147c-4d87-b2f0-4fda89e331c8")
+	assert.NoError(t, err)
+	pub, err := ParseRSAPublicKeyFromPEM(rsaKeyPair1[1])
+	assert.NoError(t, err)
+	keyring, err := NewKeyring([]*Key{{ID: "key-1", PublicKey: pub}}, "")
+	assert.NoError(t, err)
+	keyringBackend, err := NewKeyringTokenBackend(keyring)
+	assert.NoError(t, err)
+
+	v := NewTokenValidator()
+	v.TokenBackends = []TokenBackend{secretBackend, keyringBackend}
+
+	priKey, err := ParseRSAPrivateKeyFromPEM(rsaKeyPair2[0])
+	assert.NoError(t, err)
+	jwtToken := jwtlib.NewWithClaims(jwtlib.SigningMethodRS512, UserClaims{ExpiresAt: time.Now().Add(time.Minute).Unix()})
+	jwtToken.Header["kid"] = "key-9"
+	token, err := jwtToken.SignedString(priKey)
+	assert.NoError(t, err)
+
+	// secret backend 无法取得 RS 的验签密钥, keyring backend 的未知 kid 更具体
+	_, valid, err := v.ValidateToken(token)
+	assert.False(t, valid)
+	assert.True(t, errors.Is(err, ErrUnexpectedKID), "%v", err)
+	assert.True(t, errors.Is(newVerifyError(err), ErrTokenUnknownKID), "%v", err)
+	var vErr *jwtlib.ValidationError
+	if assert.True(t, errors.As(err, &vErr)) {
+		assert.Equal(t, ErrUnexpectedKID, vErr.Inner)
+	}
+
+	v.TokenBackends = nil
+	_, _, err = v.ValidateToken(token)
+	assert.Equal(t, ErrNoBackends, err)
+}
@@ -0,0 +1,116 @@
+package jwt
+
+import (
+	"errors"
+
+	jwtlib "github.com/dgrijalva/jwt-go"
+)
+
+// Verify Errors, VerifyContext 返回的 *VerifyError 可以使用 errors.Is 判断错误类型
+const (
+	ErrTokenMalformed        strError = "token is malformed"
+	ErrTokenUnverifiable     strError = "token is unverifiable"
+	ErrTokenSignatureInvalid strError = "token signature is invalid"
+	ErrTokenUnknownKID       strError = "token kid is unknown"
+	ErrTokenExpired          strError = "token is expired"
+	ErrTokenNotValidYet      strError = "token is not valid yet"
+	ErrTokenIssuedAt         strError = "token used before issued"
+	ErrTokenIssuer           strError = "token issuer is not accepted"
+	ErrTokenAudience         strError = "token audience is not accepted"
+	ErrTokenID               strError = "token id is invalid"
+	ErrTokenClaimsInvalid    strError = "token claims are invalid"
+	ErrAccessDenied          strError = "access denied by access list"
+)
+
+// VerifyError 验证 jwt 失败的错误
+// errors.Is 既可以匹配 Kind, 也可以匹配原始错误, 例如 ErrTokenExpired 和 ErrUnexpectedKID
+type VerifyError struct {
+	// Kind 错误类型, 例如 ErrTokenExpired
+	Kind error
+	// Err 原始错误, 标准声明检查失败时为 *jwtlib.ValidationError
+	Err error
+}
+
+func (e *VerifyError) Error() string {
+	if e.Err == nil || e.Err.Error() == e.Kind.Error() {
+		return e.Kind.Error()
+	}
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *VerifyError) Unwrap() error { return e.Err }
+
+// Is reports whether target is the kind of e.
+func (e *VerifyError) Is(target error) bool {
+	// nolint(errorlint): Kind 是 strError 常量
+	if e.Kind == target {
+		return true
+	}
+	// *jwtlib.ValidationError 没有实现 Unwrap
+	var vErr *jwtlib.ValidationError
+	if errors.As(e.Err, &vErr) && vErr.Inner != nil {
+		return errors.Is(vErr.Inner, target)
+	}
+	return false
+}
+
+// validationErrorKinds 按优先级排列, 同时有多个错误位时使用第一个
+var validationErrorKinds = []struct {
+	flag uint32
+	kind error
+}{
+	{jwtlib.ValidationErrorMalformed, ErrTokenMalformed},
+	{jwtlib.ValidationErrorSignatureInvalid, ErrTokenSignatureInvalid},
+	{jwtlib.ValidationErrorUnverifiable, ErrTokenUnverifiable},
+	{jwtlib.ValidationErrorExpired, ErrTokenExpired},
+	{jwtlib.ValidationErrorNotValidYet, ErrTokenNotValidYet},
+	{jwtlib.ValidationErrorIssuedAt, ErrTokenIssuedAt},
+	{jwtlib.ValidationErrorIssuer, ErrTokenIssuer},
+	{jwtlib.ValidationErrorAudience, ErrTokenAudience},
+	{jwtlib.ValidationErrorId, ErrTokenID},
+	{jwtlib.ValidationErrorClaimsInvalid, ErrTokenClaimsInvalid},
+}
+
+// newVerifyError 把 TokenValidator 返回的错误转换为 *VerifyError
+func newVerifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var verifyErr *VerifyError
+	if errors.As(err, &verifyErr) {
+		return err
+	}
+
+	var vErr *jwtlib.ValidationError
+	switch {
+	case errors.Is(err, ErrTokenRevoked):
+		return &VerifyError{Kind: ErrTokenRevoked, Err: err}
+	case errors.Is(err, ErrAccessNotAllowed), errors.Is(err, ErrNoAccessList):
+		return &VerifyError{Kind: ErrAccessDenied, Err: err}
+	case errors.Is(err, ErrInvalidParsedClaims), errors.Is(err, ErrNoParsedClaims):
+		return &VerifyError{Kind: ErrTokenMalformed, Err: err}
+	case errors.As(err, &vErr):
+		return &VerifyError{Kind: validationErrorKind(vErr), Err: err}
+	default:
+		return &VerifyError{Kind: ErrTokenUnverifiable, Err: err}
+	}
+}
+
+// validationErrorKind 返回错误位中优先级最高的错误类型
+func validationErrorKind(vErr *jwtlib.ValidationError) error {
+	if vErr == nil {
+		return ErrTokenUnverifiable
+	}
+	// jwtlib 把 ProvideKey 返回的错误放在 Inner 中
+	if vErr.Inner != nil && errors.Is(vErr.Inner, ErrUnexpectedKID) {
+		return ErrTokenUnknownKID
+	}
+	for _, k := range validationErrorKinds {
+		if vErr.Errors&k.flag != 0 {
+			return k.kind
+		}
+	}
+	return ErrTokenUnverifiable
+}
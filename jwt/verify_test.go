@@ -0,0 +1,118 @@
+package jwt
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	goa "goa.design/goa/v3/pkg"
+)
+
+func TestValidatorImpl_VerifyContext(t *testing.T) {
+	ctx := context.Background()
+	secret := "ulGoc6DKy4Ur3i+xAuOGQSS4Q3AJcCuEBzTgRkm3WSM"
+	conf := Conf{
+		Keys:       []KeyConf{{ID: "k1", Secret: secret}},
+		AccessList: []string{"deny roles suspended", "allow roles guest"},
+		Revoker:    RevokerMemory,
+	}
+	validator, err := NewValidatorImplWithConf(conf)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	exp := now.Add(time.Minute).Unix()
+	sign := func(claims UserClaims, kid, secret string) string {
+		token, err := signClaims(claims, SigningMethodHS512, kid, []byte(secret))
+		assert.NoError(t, err)
+		return token
+	}
+
+	revoked := UserClaims{ID: "revoked", ExpiresAt: now.Add(time.Minute).Unix()}
+	assert.NoError(t, validator.(ValidatorImpl).RevokeToken(ctx, revoked.ID, now.Add(time.Minute)))
+
+	cases := []struct {
+		caseName string
+		token    string
+		kind     error
+		message  string
+	}{
+		{
+			"expired",
+			sign(UserClaims{ExpiresAt: now.Add(-time.Minute).Unix()}, "k1", secret),
+			ErrTokenExpired,
+			"令牌已过期",
+		},
+		{
+			"no exp",
+			sign(UserClaims{Subject: "user-1"}, "k1", secret),
+			ErrTokenExpired,
+			"令牌已过期",
+		},
+		{
+			"not valid yet",
+			sign(UserClaims{ExpiresAt: exp, NotBefore: now.Add(time.Minute).Unix()}, "k1", secret),
+			ErrTokenNotValidYet,
+			"令牌尚未生效",
+		},
+		{
+			"bad signature",
+			sign(UserClaims{ExpiresAt: exp}, "k1", "another secret with enough length"),
+			ErrTokenSignatureInvalid,
+			"签名验证失败",
+		},
+		{
+			"unknown kid",
+			sign(UserClaims{ExpiresAt: exp}, "k2", secret),
+			ErrTokenUnknownKID,
+			"令牌签名密钥不存在",
+		},
+		{
+			"malformed",
+			"not a token",
+			ErrTokenMalformed,
+			"令牌格式错误",
+		},
+		{
+			"access denied",
+			sign(UserClaims{ExpiresAt: exp, Roles: []string{"guest", "suspended"}}, "k1", secret),
+			ErrAccessDenied,
+			"没有访问权限",
+		},
+		{
+			"revoked",
+			sign(revoked, "k1", secret),
+			ErrTokenRevoked,
+			"令牌已被吊销",
+		},
+	}
+
+	for _, tc := range cases {
+		_, err := validator.(ContextValidator).VerifyContext(ctx, tc.token)
+		assert.True(t, errors.Is(err, tc.kind), "%s: %v", tc.caseName, err)
+
+		var verifyErr *VerifyError
+		if assert.True(t, errors.As(err, &verifyErr), tc.caseName) {
+			assert.Equal(t, tc.kind, verifyErr.Kind, tc.caseName)
+		}
+
+		_, err = validator.Verify(tc.token, nil)
+		// nolint(errorlint): goa 要求 *goa.ServiceError
+		goaErr, ok := err.(*goa.ServiceError)
+		if assert.True(t, ok, "%s: %v", tc.caseName, err) {
+			assert.Equal(t, "unauthorized", goaErr.Name, tc.caseName)
+			assert.Equal(t, tc.message, goaErr.Message, tc.caseName)
+		}
+	}
+
+	// 原始错误同样可以匹配
+	_, err = validator.(ContextValidator).VerifyContext(ctx, sign(UserClaims{ExpiresAt: exp}, "k2", secret))
+	assert.True(t, errors.Is(err, ErrUnexpectedKID))
+
+	claims, err := validator.(ContextValidator).VerifyContext(ctx, sign(UserClaims{ExpiresAt: exp, Subject: "user-1"}, "k1", secret))
+	assert.NoError(t, err)
+	if assert.NotNil(t, claims) {
+		assert.Equal(t, "user-1", claims.Subject)
+	}
+}